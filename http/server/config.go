@@ -0,0 +1,150 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/netutil"
+)
+
+const (
+	// ReadHeaderTimeout - default time allowed to read request headers,
+	// protects against slowloris
+	ReadHeaderTimeout = 5 * time.Second
+	// ReadTimeout - default time allowed to read a whole request
+	ReadTimeout = 30 * time.Second
+	// WriteTimeout - default time allowed to write a response
+	WriteTimeout = 60 * time.Second
+	// IdleTimeout - default time a keep-alive connection is kept idle
+	IdleTimeout = 120 * time.Second
+	// MaxHeaderBytes - default maximum size of request headers
+	MaxHeaderBytes = 64 << 10
+	// MaxBodyBytes - default maximum size of a request body
+	MaxBodyBytes = 10 << 20
+)
+
+// Config - limits of the http server, zero values use the defaults above and
+// negative values disable the limit, Serve, ServeTLS and RunTLS apply every
+// default while Run and RunS apply the header timeout, idle timeout and
+// header size defaults and leave the read and write timeouts and the body
+// size open until SetConfig is called, as existing streaming or upload
+// handlers may rely on it, the 60s WriteTimeout cuts streaming and
+// server-sent events responses, disable it with WriteTimeout: -1 for those
+type Config struct {
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	// MaxConns - connections accepted at once, further connections wait
+	// until one is closed, zero is unlimited
+	MaxConns int
+	// MaxBodyBytes - requests with a larger body fail to read it and
+	// are answered with 413 when ContentLength already exceeds it
+	MaxBodyBytes int64
+}
+
+// legacyConfig - used by Run and RunS until SetConfig is called, slow or
+// idle clients are cut off but requests and responses may take any time
+// and bodies any size
+var legacyConfig = Config{
+	ReadTimeout:  -1,
+	WriteTimeout: -1,
+	MaxBodyBytes: -1,
+}
+
+// limits - config shared by copies of the router, read on every request
+type limits struct {
+	mu      sync.RWMutex
+	cfg     Config
+	enabled bool
+}
+
+// enable - makes the defaults apply from now on even if SetConfig was never
+// called, used by Serve, ServeTLS and RunTLS
+func (lm *limits) enable() {
+	lm.mu.Lock()
+	lm.enabled = true
+	lm.mu.Unlock()
+}
+
+// get - returns the config in effect
+func (lm *limits) get() Config {
+	lm.mu.RLock()
+	defer lm.mu.RUnlock()
+	if !lm.enabled {
+		return legacyConfig
+	}
+	return lm.cfg
+}
+
+// SetConfig - changes the limits of the http server, timeouts apply to
+// servers started afterwards, the body limit applies immediately
+func (rtr *Router) SetConfig(cfg Config) {
+	rtr.limits.mu.Lock()
+	rtr.limits.cfg = cfg
+	rtr.limits.enabled = true
+	rtr.limits.mu.Unlock()
+}
+
+// orDefault - returns the default when d is zero and no limit when negative
+func orDefault(d time.Duration, def time.Duration) time.Duration {
+	if d == 0 {
+		return def
+	}
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// httpServer - returns a server for handler with the limits of cfg
+func httpServer(hostport string, handler http.Handler, cfg Config) *http.Server {
+	maxHeader := cfg.MaxHeaderBytes
+	if maxHeader == 0 {
+		maxHeader = MaxHeaderBytes
+	} else if maxHeader < 0 {
+		maxHeader = 0
+	}
+	return &http.Server{
+		Addr:              hostport,
+		Handler:           handler,
+		ReadHeaderTimeout: orDefault(cfg.ReadHeaderTimeout, ReadHeaderTimeout),
+		ReadTimeout:       orDefault(cfg.ReadTimeout, ReadTimeout),
+		WriteTimeout:      orDefault(cfg.WriteTimeout, WriteTimeout),
+		IdleTimeout:       orDefault(cfg.IdleTimeout, IdleTimeout),
+		MaxHeaderBytes:    maxHeader,
+	}
+}
+
+// listen - listens on hostport, limiting concurrent connections
+func listen(hostport string, cfg Config) (net.Listener, error) {
+	ln, e := net.Listen("tcp", hostport)
+	if e != nil {
+		return nil, e
+	}
+	if cfg.MaxConns > 0 {
+		ln = netutil.LimitListener(ln, cfg.MaxConns)
+	}
+	return ln, nil
+}
+
+// limitBody - middleware capping the size of request bodies
+func (rtr *Router) limitBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		max := rtr.limits.get().MaxBodyBytes
+		if max == 0 {
+			max = MaxBodyBytes
+		}
+		if max > 0 && r.Body != nil {
+			if r.ContentLength > max {
+				WriteError(w, r, NewError(http.StatusRequestEntityTooLarge, "body_too_large", "Request body too large"))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, max)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPServerConfig(t *testing.T) {
+	router, _ := New(nil, nil)
+	// Run and RunS protect against slow clients but do not limit the
+	// duration of requests and responses
+	srv := httpServer(":0", router.Engine, router.limits.get())
+	if srv.ReadHeaderTimeout != ReadHeaderTimeout || srv.IdleTimeout != IdleTimeout || srv.MaxHeaderBytes != MaxHeaderBytes ||
+		srv.ReadTimeout != 0 || srv.WriteTimeout != 0 {
+		t.Errorf("unexpected config before SetConfig %+v", srv)
+	}
+	if router.limits.get() != legacyConfig {
+		t.Error("reading the config should not change it")
+	}
+	// Serve and RunTLS apply the secure defaults
+	router.limits.enable()
+	srv = httpServer(":0", router.Engine, router.limits.get())
+	if srv.ReadHeaderTimeout != ReadHeaderTimeout || srv.ReadTimeout != ReadTimeout ||
+		srv.WriteTimeout != WriteTimeout || srv.IdleTimeout != IdleTimeout || srv.MaxHeaderBytes != MaxHeaderBytes {
+		t.Errorf("expected secure defaults, got %+v", srv)
+	}
+	router.SetConfig(Config{ReadHeaderTimeout: time.Second, WriteTimeout: -1, MaxHeaderBytes: -1})
+	srv = httpServer(":0", router.Engine, router.limits.get())
+	if srv.ReadHeaderTimeout != time.Second || srv.WriteTimeout != 0 || srv.MaxHeaderBytes != 0 || srv.ReadTimeout != ReadTimeout {
+		t.Errorf("unexpected config %+v", srv)
+	}
+}
+
+func TestLegacyBodyLimit(t *testing.T) {
+	router, _ := New(nil, nil)
+	router.SetLogger("empty")
+	router.Post("/echo", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	})
+	body := strings.NewReader(strings.Repeat("a", MaxBodyBytes+1))
+	res := httptest.NewRecorder()
+	router.Engine.ServeHTTP(res, httptest.NewRequest("POST", "/echo", body))
+	if res.Code != http.StatusOK {
+		t.Errorf("bodies should not be limited before SetConfig, got %d", res.Code)
+	}
+}
+
+func TestReadHeaderTimeout(t *testing.T) {
+	router, _ := New(nil, nil)
+	router.SetLogger("empty")
+	router.SetConfig(Config{ReadHeaderTimeout: 100 * time.Millisecond})
+	addr := start(t, func(addr string) error {
+		return router.Run("http", addr)
+	})
+
+	// a client sending its headers too slowly is disconnected
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Write([]byte("GET / HTTP/1.1\r\n"))
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := io.ReadAll(c); err != nil {
+		t.Errorf("expected the server to close the connection, got %v", err)
+	}
+}
+
+func TestMaxConns(t *testing.T) {
+	router, _ := New(nil, nil)
+	router.SetConfig(Config{MaxConns: 1})
+	ln, err := listen("127.0.0.1:0", router.limits.get())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	for i := 0; i < 2; i++ {
+		c, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+	}
+	first, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, _ := ln.Accept()
+		accepted <- c
+	}()
+	select {
+	case <-accepted:
+		t.Fatal("second connection accepted over the limit")
+	case <-time.After(100 * time.Millisecond):
+	}
+	first.Close()
+	select {
+	case c := <-accepted:
+		c.Close()
+	case <-time.After(time.Second):
+		t.Fatal("second connection not accepted after the first closed")
+	}
+}
+
+func TestMaxBodyBytes(t *testing.T) {
+	router, _ := New(nil, nil)
+	router.SetLogger("empty")
+	router.SetConfig(Config{MaxBodyBytes: 8})
+	router.Post("/echo", func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.Write(b)
+	})
+	tests := []struct {
+		body   string
+		length int64
+		status int
+	}{
+		{"small", 5, http.StatusOK},
+		{"far too large", 13, http.StatusRequestEntityTooLarge},
+		// unknown length is cut while reading
+		{"far too large", -1, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/echo", strings.NewReader(tt.body))
+		req.ContentLength = tt.length
+		res := httptest.NewRecorder()
+		router.Engine.ServeHTTP(res, req)
+		if res.Code != tt.status {
+			t.Errorf("%q with length %d: expected %d, got %d", tt.body, tt.length, tt.status, res.Code)
+		}
+	}
+
+	// negative disables the limit
+	router.SetConfig(Config{MaxBodyBytes: -1})
+	req := httptest.NewRequest("POST", "/echo", strings.NewReader(strings.Repeat("a", 100)))
+	res := httptest.NewRecorder()
+	router.Engine.ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		t.Errorf("expected no limit, got %d", res.Code)
+	}
+}
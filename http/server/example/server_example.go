@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"net/http"
 
@@ -23,19 +24,45 @@ func main() {
 	// for example ~/go/bin/esc -o testdir/test.go -pkg static -ignore=".*.go" testdir
 	//rtr.StaticFs("/test/", static.FS(false))
 
+	// liveness and readiness probes
+	rtr.Health(server.Check{Name: "self", Fn: func(ctx context.Context) error {
+		return nil
+	}})
+
+	// prometheus metrics by route pattern
+	rtr.Metrics("/metrics", nil)
+
 	// api definition
 	rtr.Get("/welcome", func(w http.ResponseWriter, r *http.Request) {
 		server.JSON(w, r, map[string]string{
 			"status": "success",
 		})
 	})
+	type greet struct {
+		Name string `query:"name" validate:"required,max=20"`
+	}
+	rtr.Get("/greet", server.Handle(func(ctx context.Context, req greet) (map[string]string, error) {
+		return map[string]string{"greeting": "hello " + req.Name}, nil
+	}))
 	rtr.Get("/crash", func(w http.ResponseWriter, r *http.Request) {
 		panic(errors.New("deliberate crash"))
 	})
 
+	rtr.Get("/crash-string", func(w http.ResponseWriter, r *http.Request) {
+		panic("deliberate crash")
+	})
+	rtr.OnPanic(func(r *http.Request, rc interface{}, stack []byte) {
+		// report to an error tracker
+	})
+
 	// Disable automatic logging
 	rtr.SetLogRequest(false)
 
-	// run server with cleartext http/2
-	rtr.Run("http", ":8080")
+	// close dependencies once in-flight requests are drained
+	rtr.OnShutdown("cleanup", func(ctx context.Context) error {
+		return nil
+	})
+
+	// serve until SIGTERM or SIGINT, then shut down gracefully
+	rtr.Serve(context.Background(), "http", ":8080")
 }
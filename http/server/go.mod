@@ -1,11 +1,11 @@
-//v0.1.1
+//v0.1.26
 module github.com/kelchy/go-lib/http/server
 
 require (
 	github.com/go-chi/chi v1.5.4
 	github.com/go-chi/cors v1.2.1
 	github.com/go-chi/render v1.0.2
-	github.com/kelchy/go-lib/log v0.0.10
+	github.com/kelchy/go-lib/log v0.0.35
 	github.com/urfave/negroni v1.0.0
 	golang.org/x/net v0.0.0-20220826154423-83b083e8dc8b
 )
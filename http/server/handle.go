@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/kelchy/go-lib/log"
+)
+
+// handleLog - logs errors of handlers adapted by Handle when they are not
+// served by a Router, otherwise the logger of the router is used
+var handleLog, _ = log.New("")
+
+// DecodeError - the request could not be decoded
+type DecodeError struct {
+	Field string
+	Err   error
+}
+
+// Error - describes the field that failed to decode
+func (de *DecodeError) Error() string {
+	if de.Field == "" {
+		return "Invalid request body: " + de.Err.Error()
+	}
+	return "Invalid value for " + de.Field + ": " + de.Err.Error()
+}
+
+// Unwrap - returns the underlying error
+func (de *DecodeError) Unwrap() error {
+	return de.Err
+}
+
+// Handle - adapts fn to a http.HandlerFunc, the request is decoded into Req
+// from the json body, then from query parameters (tag query:"name") and url
+// parameters (tag param:"name"), validated with Validate and passed to fn,
+// the response is written as json, errors are written with WriteError and
+// server errors logged with the logger of the router
+func Handle[Req any, Resp any](fn func(ctx context.Context, req Req) (Resp, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req Req
+		if e := Decode(r, &req); e != nil {
+			writeError(w, r, e)
+			return
+		}
+		resp, e := fn(r.Context(), req)
+		if e != nil {
+			writeError(w, r, e)
+			return
+		}
+		JSON(w, r, resp)
+	}
+}
+
+// Decode - decodes the json body, query and url parameters of r into v, a
+// pointer to a struct, and validates it, see Handle. The body must hold a
+// single json value. A field set by the body is overwritten by a query
+// parameter present in the request, which is overwritten in turn by a non
+// empty url parameter
+func Decode(r *http.Request, v interface{}) error {
+	if r.Body != nil && r.ContentLength != 0 {
+		dec := json.NewDecoder(r.Body)
+		e := dec.Decode(v)
+		if e != nil && !errors.Is(e, io.EOF) {
+			return &DecodeError{Err: e}
+		}
+		if e == nil {
+			// dec.More misses a stray closing delimiter, only the end of the
+			// body is accepted after the value
+			if _, e = dec.Token(); !errors.Is(e, io.EOF) {
+				return &DecodeError{Err: errors.New("unexpected data after the json value")}
+			}
+		}
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("Decode needs a non nil pointer")
+	}
+	rv = rv.Elem()
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	query := r.URL.Query()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		if name := sf.Tag.Get("query"); name != "" && name != "-" {
+			if values, ok := query[name]; ok {
+				if e := setField(rv.Field(i), values); e != nil {
+					return &DecodeError{Field: name, Err: e}
+				}
+			}
+		}
+		if name := sf.Tag.Get("param"); name != "" && name != "-" {
+			if value := URLParam(r, name); value != "" {
+				if e := setField(rv.Field(i), []string{value}); e != nil {
+					return &DecodeError{Field: name, Err: e}
+				}
+			}
+		}
+	}
+	return Validate(v)
+}
+
+// setField - parses values into fv, slices take every value, other kinds
+// the first
+func setField(fv reflect.Value, values []string) error {
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		fv = fv.Elem()
+	}
+	if fv.Kind() == reflect.Slice {
+		s := reflect.MakeSlice(fv.Type(), 0, len(values))
+		for _, v := range values {
+			// comma separated lists are accepted as well as repeated keys
+			for _, part := range strings.Split(v, ",") {
+				elem := reflect.New(fv.Type().Elem()).Elem()
+				if e := setValue(elem, part); e != nil {
+					return e
+				}
+				s = reflect.Append(s, elem)
+			}
+		}
+		fv.Set(s)
+		return nil
+	}
+	return setValue(fv, values[0])
+}
+
+func setValue(fv reflect.Value, s string) error {
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Bool:
+		b, e := strconv.ParseBool(s)
+		if e != nil {
+			return errors.New("not a boolean")
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, e := strconv.ParseInt(s, 10, fv.Type().Bits())
+		if e != nil {
+			return errors.New("not an integer")
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, e := strconv.ParseUint(s, 10, fv.Type().Bits())
+		if e != nil {
+			return errors.New("not a positive integer")
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, e := strconv.ParseFloat(s, fv.Type().Bits())
+		if e != nil {
+			return errors.New("not a number")
+		}
+		fv.SetFloat(f)
+	default:
+		return errors.New("unsupported type " + fv.Type().String())
+	}
+	return nil
+}
+
+// writeError - writes e with WriteError, server errors are logged as their
+// cause is hidden from clients
+func writeError(w http.ResponseWriter, r *http.Request, e error) {
+	if ToError(e).Status >= http.StatusInternalServerError {
+		l, ok := r.Context().Value(logKey).(log.Log)
+		if !ok {
+			l = handleLog
+		}
+		l.Ctx(r.Context()).Error(r.URL.Path, e)
+	}
+	WriteError(w, r, e)
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kelchy/go-lib/log"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type createOrder struct {
+	ShopID  int      `param:"shop"`
+	DryRun  bool     `query:"dry_run"`
+	Tags    []string `query:"tag"`
+	Item    string   `json:"item" validate:"required,max=10"`
+	Qty     int      `json:"qty" validate:"min=1,max=100"`
+	Channel string   `json:"channel" validate:"oneof=web app"`
+	Note    *string  `json:"note" validate:"min=3"`
+	Address address  `json:"address"`
+}
+
+type order struct {
+	ShopID int      `json:"shop_id"`
+	Item   string   `json:"item"`
+	Qty    int      `json:"qty"`
+	DryRun bool     `json:"dry_run"`
+	Tags   []string `json:"tags"`
+}
+
+func TestHandle(t *testing.T) {
+	router, _ := New(nil, nil)
+	router.SetLogger("empty")
+	router.Post("/shops/{shop}/orders", Handle(func(ctx context.Context, req createOrder) (order, error) {
+		if req.Item == "broken" {
+			return order{}, errors.New("database down")
+		}
+		return order{ShopID: req.ShopID, Item: req.Item, Qty: req.Qty, DryRun: req.DryRun, Tags: req.Tags}, nil
+	}))
+	post := func(path string, body string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		router.Engine.ServeHTTP(res, httptest.NewRequest("POST", path, strings.NewReader(body)))
+		return res
+	}
+
+	res := post("/shops/7/orders?dry_run=true&tag=a,b&tag=c", `{"item":"apple","qty":2,"channel":"web","address":{"city":"SG"}}`)
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", res.Code, res.Body)
+	}
+	var got order
+	json.Unmarshal(res.Body.Bytes(), &got)
+	if got.ShopID != 7 || got.Item != "apple" || got.Qty != 2 || !got.DryRun || strings.Join(got.Tags, "") != "abc" {
+		t.Errorf("unexpected decoding %+v", got)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		fields []string
+	}{
+		{"invalid json", "/shops/7/orders", `{"item":`, http.StatusBadRequest, nil},
+		{"trailing json", "/shops/7/orders", `{"item":"apple","qty":1,"channel":"web","address":{"city":"SG"}} {}`, http.StatusBadRequest, nil},
+		{"trailing delimiter", "/shops/7/orders", `{"item":"apple","qty":1,"channel":"web","address":{"city":"SG"}}}`, http.StatusBadRequest, nil},
+		{"invalid param", "/shops/x/orders", `{}`, http.StatusBadRequest, nil},
+		{"invalid query", "/shops/7/orders?dry_run=maybe", `{}`, http.StatusBadRequest, nil},
+		{"validation", "/shops/7/orders", `{"item":"far too long item","qty":0,"channel":"fax","note":"hi"}`, http.StatusBadRequest,
+			[]string{"item", "qty", "channel", "note", "address.city"}},
+		{"handler error", "/shops/7/orders", `{"item":"broken","qty":1,"channel":"app","address":{"city":"SG"}}`, http.StatusInternalServerError, nil},
+	}
+	for _, tt := range tests {
+		res := post(tt.path, tt.body)
+		if res.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d %s", tt.name, tt.status, res.Code, res.Body)
+		}
+		var body struct {
+			Error  string       `json:"error"`
+			Fields []FieldError `json:"fields"`
+		}
+		json.Unmarshal(res.Body.Bytes(), &body)
+		if body.Error == "" || strings.Contains(body.Error, "database") {
+			t.Errorf("%s: unexpected error %q", tt.name, body.Error)
+		}
+		if len(body.Fields) != len(tt.fields) {
+			t.Errorf("%s: expected fields %v, got %+v", tt.name, tt.fields, body.Fields)
+			continue
+		}
+		for i, f := range tt.fields {
+			if body.Fields[i].Field != f {
+				t.Errorf("%s: expected field %s, got %+v", tt.name, f, body.Fields[i])
+			}
+		}
+	}
+}
+
+func TestHandleLogger(t *testing.T) {
+	router, _ := New(nil, nil)
+	var out bytes.Buffer
+	l, _ := log.NewWithOptions(log.WithOutput(&out, &out))
+	router.SetLog(l)
+	router.SetLogRequest(false)
+	router.Get("/shops/{shop}", Handle(func(ctx context.Context, req struct{}) (order, error) {
+		return order{}, errors.New("database down")
+	}))
+	get := func(path string) {
+		router.Engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	get("/shops/1?tag=a")
+	if !strings.Contains(out.String(), "database down") || !strings.Contains(out.String(), "request_id") {
+		t.Errorf("expected the error in the router log, got %s", out.String())
+	}
+	out.Reset()
+	router.SetLogger("empty")
+	get("/shops/1")
+	if out.Len() != 0 {
+		t.Errorf("an empty logger should silence handler errors, got %s", out.String())
+	}
+}
+
+func TestDecodePrecedence(t *testing.T) {
+	router, _ := New(nil, nil)
+	router.SetLogger("empty")
+	var got createOrder
+	router.Post("/shops/{shop}", func(w http.ResponseWriter, r *http.Request) {
+		if e := Decode(r, &got); e != nil {
+			t.Error(e)
+		}
+	})
+	// json has no say over fields tagged for query or url parameters that
+	// are present in the request
+	body := `{"ShopID":1,"DryRun":false,"Tags":["json"],"item":"apple","qty":1,"channel":"web","address":{"city":"SG"}}`
+	router.Engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/shops/7?dry_run=true", strings.NewReader(body)))
+	if got.ShopID != 7 || !got.DryRun || strings.Join(got.Tags, "") != "json" {
+		t.Errorf("unexpected precedence %+v", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(struct {
+		Name string `validate:"required"`
+	}{Name: "x"}); err != nil {
+		t.Errorf("expected valid, got %v", err)
+	}
+	err := Validate(&struct {
+		Name string `validate:"bogus"`
+	}{})
+	var ve *ValidationError
+	if !errors.As(err, &ve) || ve.Fields[0].Msg != "has an unknown rule" {
+		t.Errorf("expected unknown rule, got %v", err)
+	}
+}
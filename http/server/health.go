@@ -0,0 +1,175 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CheckTimeout - default time a health check may take before it fails
+const CheckTimeout = 5 * time.Second
+
+// Check - named dependency check run by /readyz
+type Check struct {
+	// Name - key of the check in the report, e.g. mongo
+	Name string
+	// Fn - returns nil when the dependency is healthy, it should respect
+	// ctx cancellation
+	Fn func(ctx context.Context) error
+	// Timeout - defaults to CheckTimeout
+	Timeout time.Duration
+	// Cache - a result is reused for this long, protecting the dependency
+	// from frequent probes, zero runs the check on every request
+	Cache time.Duration
+}
+
+// CheckResult - outcome of a check in the health report
+type CheckResult struct {
+	Status string  `json:"status"`
+	Error  string  `json:"error,omitempty"`
+	Ms     float64 `json:"ms"`
+	// checked - when the check ran, used for caching
+	checked time.Time
+}
+
+// HealthReport - body of /livez and /readyz
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// check - a Check with its cached result
+type check struct {
+	Check
+	mu   sync.Mutex
+	last CheckResult
+	// flight - the run in progress, shared by the probes asking meanwhile
+	flight *flight
+}
+
+// flight - a single run of a check, res is set before done is closed
+type flight struct {
+	done chan struct{}
+	res  CheckResult
+}
+
+// Health - mounts /livez, answering 200 while the process serves requests,
+// and /readyz, answering 200 when every check passes and the server is not
+// shutting down, 503 otherwise, both return a json HealthReport and are
+// left out of the access log
+func (rtr *Router) Health(checks ...Check) {
+	list := make([]*check, len(checks))
+	for i, c := range checks {
+		if c.Timeout <= 0 {
+			c.Timeout = CheckTimeout
+		}
+		list[i] = &check{Check: c}
+	}
+	rtr.logSkipPath = append(rtr.logSkipPath, "/livez", "/readyz")
+	rtr.Engine.Get("/livez", func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, HealthReport{Status: "ok"})
+	})
+	rtr.Engine.Get("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, rtr.readiness(r.Context(), list))
+	})
+}
+
+// readiness - runs the checks concurrently and builds the report
+func (rtr Router) readiness(ctx context.Context, list []*check) HealthReport {
+	report := HealthReport{Status: "ok", Checks: make(map[string]CheckResult, len(list)+1)}
+	if !rtr.Ready() {
+		report.Status = "fail"
+		report.Checks["shutdown"] = CheckResult{Status: "fail", Error: "server is shutting down"}
+	}
+	results := make([]CheckResult, len(list))
+	var wg sync.WaitGroup
+	for i, c := range list {
+		wg.Add(1)
+		go func(i int, c *check) {
+			defer wg.Done()
+			results[i] = c.run(ctx)
+		}(i, c)
+	}
+	wg.Wait()
+	for i, c := range list {
+		if results[i].Status != "ok" {
+			report.Status = "fail"
+		}
+		report.Checks[c.Name] = results[i]
+	}
+	return report
+}
+
+// run - returns the cached result or waits for a run of the check, started
+// if none is in progress, so concurrent probes share one call of Fn and a
+// probe going away neither waits longer nor cancels the run
+func (c *check) run(ctx context.Context) CheckResult {
+	c.mu.Lock()
+	if c.Cache > 0 && !c.last.checked.IsZero() && time.Since(c.last.checked) < c.Cache {
+		defer c.mu.Unlock()
+		return c.last
+	}
+	f := c.flight
+	if f == nil {
+		f = &flight{done: make(chan struct{})}
+		c.flight = f
+		go c.refresh(f)
+	}
+	c.mu.Unlock()
+	select {
+	case <-f.done:
+		return f.res
+	case <-ctx.Done():
+		return CheckResult{Status: "fail", Error: ctx.Err().Error()}
+	}
+}
+
+// refresh - runs the check within its timeout and publishes the result
+func (c *check) refresh(f *flight) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	t1 := time.Now()
+	errc := make(chan error, 1)
+	go func() {
+		// a panicking check, e.g. with a nil client, must not crash the
+		// process, it runs outside of any handler catchall could recover
+		defer func() {
+			if rc := recover(); rc != nil {
+				errc <- fmt.Errorf("check panicked: %v", rc)
+			}
+		}()
+		errc <- c.Fn(ctx)
+	}()
+	var e error
+	select {
+	case e = <-errc:
+	case <-ctx.Done():
+		// the check ignored ctx, do not wait for it
+		e = errors.New("check timed out after " + c.Timeout.String())
+	}
+	res := CheckResult{Status: "ok", Ms: float64(time.Since(t1).Microseconds()) / 1000, checked: time.Now()}
+	if e != nil {
+		res.Status = "fail"
+		res.Error = e.Error()
+	}
+	c.mu.Lock()
+	c.last = res
+	c.flight = nil
+	c.mu.Unlock()
+	f.res = res
+	close(f.done)
+}
+
+func writeReport(w http.ResponseWriter, report HealthReport) {
+	body, _ := json.Marshal(report)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(body)
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	router, _ := New(nil, nil)
+	router.SetLogger("empty")
+	var calls int32
+	var redisErr error
+	router.Health(
+		Check{Name: "mongo", Cache: time.Minute, Fn: func(ctx context.Context) error {
+			atomic.AddInt32(&calls, 1)
+			return nil
+		}},
+		Check{Name: "redis", Fn: func(ctx context.Context) error {
+			return redisErr
+		}},
+		Check{Name: "slow", Timeout: 20 * time.Millisecond, Fn: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+	)
+	get := func(path string) (int, HealthReport) {
+		res := httptest.NewRecorder()
+		router.Engine.ServeHTTP(res, httptest.NewRequest("GET", path, nil))
+		var report HealthReport
+		if err := json.Unmarshal(res.Body.Bytes(), &report); err != nil {
+			t.Fatal(err)
+		}
+		return res.Code, report
+	}
+
+	if code, report := get("/livez"); code != http.StatusOK || report.Status != "ok" {
+		t.Errorf("livez: expected ok, got %d %+v", code, report)
+	}
+	code, report := get("/readyz")
+	if code != http.StatusServiceUnavailable || report.Status != "fail" {
+		t.Errorf("readyz: expected fail, got %d %+v", code, report)
+	}
+	if report.Checks["mongo"].Status != "ok" || report.Checks["redis"].Status != "ok" {
+		t.Errorf("unexpected checks %+v", report.Checks)
+	}
+	if report.Checks["slow"].Status != "fail" || report.Checks["slow"].Error == "" {
+		t.Errorf("slow check should time out, got %+v", report.Checks["slow"])
+	}
+
+	redisErr = errors.New("connection refused")
+	_, report = get("/readyz")
+	if report.Checks["redis"].Error != "connection refused" {
+		t.Errorf("expected redis failure, got %+v", report.Checks["redis"])
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("mongo check should be cached, ran %d times", n)
+	}
+	if !contains(router.logSkipPath, "/readyz") {
+		t.Error("health endpoints should not be access logged")
+	}
+}
+
+func TestReadyzShutdown(t *testing.T) {
+	router, _ := New(nil, nil)
+	router.SetLogger("empty")
+	router.Health()
+	res := httptest.NewRecorder()
+	router.Engine.ServeHTTP(res, httptest.NewRequest("GET", "/readyz", nil))
+	if res.Code != http.StatusOK {
+		t.Errorf("expected ready, got %d", res.Code)
+	}
+	router.lifecycle.draining = true
+	res = httptest.NewRecorder()
+	router.Engine.ServeHTTP(res, httptest.NewRequest("GET", "/readyz", nil))
+	if res.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected not ready while draining, got %d", res.Code)
+	}
+}
+
+func TestCheckIgnoringContext(t *testing.T) {
+	c := &check{Check: Check{Name: "stuck", Timeout: 10 * time.Millisecond, Fn: func(ctx context.Context) error {
+		time.Sleep(200 * time.Millisecond)
+		return nil
+	}}}
+	t1 := time.Now()
+	if res := c.run(context.Background()); res.Status != "fail" {
+		t.Errorf("expected timeout, got %+v", res)
+	}
+	if time.Since(t1) > 100*time.Millisecond {
+		t.Error("should not wait for a check ignoring ctx")
+	}
+}
+
+func TestCheckSingleFlight(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	c := &check{Check: Check{Name: "slow", Timeout: time.Second, Fn: func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return nil
+	}}}
+
+	// a probe that goes away returns at once, the run goes on for the others
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if res := c.run(ctx); res.Status != "fail" {
+		t.Errorf("expected the cancelled probe to fail, got %+v", res)
+	}
+	var wg sync.WaitGroup
+	results := make([]CheckResult, 5)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = c.run(context.Background())
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	for _, res := range results {
+		if res.Status != "ok" {
+			t.Errorf("expected ok, got %+v", res)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("concurrent probes should share one run, ran %d times", n)
+	}
+	// without cache the next probe runs the check again
+	c.run(context.Background())
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("expected a new run, ran %d times", n)
+	}
+}
+
+func TestCheckPanics(t *testing.T) {
+	c := &check{Check: Check{Name: "mongo", Timeout: time.Second, Fn: func(ctx context.Context) error {
+		var client *http.Client
+		_, err := client.Do(nil)
+		return err
+	}}}
+	res := c.run(context.Background())
+	if res.Status != "fail" || !strings.Contains(res.Error, "check panicked") {
+		t.Errorf("expected the panic as a failure, got %+v", res)
+	}
+}
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// HTTP2Config - limits applied to http/2 connections in h2 and h2c modes,
+// zero values keep the defaults of golang.org/x/net/http2
+type HTTP2Config struct {
+	// MaxConcurrentStreams - streams a client may have open at once per
+	// connection, defaults to 250
+	MaxConcurrentStreams uint32
+	// MaxReadFrameSize - largest frame the server accepts, between 16KB and
+	// 16MB, defaults to 1MB
+	MaxReadFrameSize uint32
+	// IdleTimeout - idle connections are closed after this duration
+	IdleTimeout time.Duration
+}
+
+// SetHTTP2 - changes the limits of http/2 connections
+func (rtr *Router) SetHTTP2(cfg HTTP2Config) {
+	rtr.http2 = cfg
+}
+
+// h2server - returns the http/2 server configured with the router limits
+func (rtr Router) h2server() *http2.Server {
+	return &http2.Server{
+		MaxConcurrentStreams: rtr.http2.MaxConcurrentStreams,
+		MaxReadFrameSize:     rtr.http2.MaxReadFrameSize,
+		IdleTimeout:          rtr.http2.IdleTimeout,
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+func TestRunSH2(t *testing.T) {
+	crt, key := issue(t, nil, 1, false).write(t, t.TempDir())
+	router, _ := New(nil, nil)
+	router.SetLogger("empty")
+	router.SetHTTP2(HTTP2Config{MaxConcurrentStreams: 10, IdleTimeout: time.Second})
+	router.Get("/proto", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+	addr := start(t, func(addr string) error {
+		return router.RunS("h2", addr, crt, key)
+	})
+
+	tlsCfg := &tls.Config{InsecureSkipVerify: true}
+	// http/2 is negotiated with ALPN
+	h2 := &http.Client{Transport: &http2.Transport{TLSClientConfig: tlsCfg}}
+	res, err := h2.Get("https://" + addr + "/proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.ProtoMajor != 2 {
+		t.Errorf("expected http/2, got %s", res.Proto)
+	}
+
+	// clients without http/2 fall back to http/1.1
+	h1 := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsCfg}}
+	res, err = h1.Get("https://" + addr + "/proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.ProtoMajor != 1 {
+		t.Errorf("expected http/1.1, got %s", res.Proto)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	router, _ := New(nil, nil)
+	router.SetLogger("empty")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	addr := ln.Addr().String()
+
+	// listener errors are surfaced in every mode
+	if err := router.Run("h2c", addr); err == nil {
+		t.Error("expected error from h2c when the address is in use")
+	}
+	if err := router.Run("http", addr); err == nil {
+		t.Error("expected error from http when the address is in use")
+	}
+	if err := router.RunS("h2", freeAddr(t), "missing.crt", "missing.key"); err == nil {
+		t.Error("expected error from h2 without certificates")
+	}
+	if err := router.Run("ftp", addr); err == nil {
+		t.Error("expected error for unknown proto")
+	}
+	if err := router.RunS("ftp", addr, "", ""); err == nil {
+		t.Error("expected error for unknown proto")
+	}
+}
@@ -0,0 +1,14 @@
+//go:build go1.19
+
+package server
+
+import (
+	"errors"
+	"net/http"
+)
+
+// isBodyTooLarge - true if err comes from http.MaxBytesReader
+func isBodyTooLarge(err error) bool {
+	var mbe *http.MaxBytesError
+	return errors.As(err, &mbe)
+}
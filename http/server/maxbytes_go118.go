@@ -0,0 +1,12 @@
+//go:build !go1.19
+
+package server
+
+// errBodyTooLarge - message of the error returned by http.MaxBytesReader,
+// go 1.18 has no http.MaxBytesError to match instead
+const errBodyTooLarge = "http: request body too large"
+
+// isBodyTooLarge - true if err comes from http.MaxBytesReader
+func isBodyTooLarge(err error) bool {
+	return hasMessage(err, errBodyTooLarge)
+}
@@ -0,0 +1,230 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+var (
+	// DurationBuckets - default upper bounds in seconds of the request
+	// duration histogram
+	DurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	// SizeBuckets - default upper bounds in bytes of the response size
+	// histogram
+	SizeBuckets = []float64{100, 1000, 10000, 100000, 1000000, 10000000}
+)
+
+// metrics - request metrics recorded by catchall
+type metrics struct {
+	// inFlight - first so it is 64-bit aligned for atomic use on 32-bit platforms
+	inFlight  int64
+	mu        sync.Mutex
+	requests  map[requestKey]uint64
+	durations map[routeKey]*histogram
+	sizes     map[routeKey]*histogram
+	durationB []float64
+	sizeB     []float64
+}
+
+type routeKey struct {
+	method string
+	route  string
+}
+
+type requestKey struct {
+	routeKey
+	status int
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Metrics - records request counts, latencies, response sizes and requests
+// in flight, labelled by the chi route pattern, and serves them in the
+// prometheus text format on path, buckets are upper bounds in seconds in any
+// order, nil uses DurationBuckets
+func (rtr *Router) Metrics(path string, buckets []float64) {
+	buckets = bounds(buckets)
+	if len(buckets) == 0 {
+		buckets = bounds(DurationBuckets)
+	}
+	rtr.metrics = &metrics{
+		requests:  map[requestKey]uint64{},
+		durations: map[routeKey]*histogram{},
+		sizes:     map[routeKey]*histogram{},
+		durationB: buckets,
+		sizeB:     SizeBuckets,
+	}
+	rtr.Engine.Get(path, rtr.metrics.ServeHTTP)
+}
+
+// bounds - returns a sorted copy of buckets without duplicates, NaN and
+// +Inf, which is always added when written
+func bounds(buckets []float64) []float64 {
+	list := make([]float64, 0, len(buckets))
+	for _, b := range buckets {
+		if !math.IsNaN(b) && !math.IsInf(b, 1) {
+			list = append(list, b)
+		}
+	}
+	sort.Float64s(list)
+	unique := list[:0]
+	for _, b := range list {
+		if len(unique) == 0 || b != unique[len(unique)-1] {
+			unique = append(unique, b)
+		}
+	}
+	return unique
+}
+
+// route - pattern of the matched route, requests matching no route share
+// one label to keep the number of series bounded
+func route(r *http.Request) string {
+	if p := routePattern(r); p != "" {
+		return p
+	}
+	return "unmatched"
+}
+
+// routePattern - returns the pattern of the route chi matched or ""
+func routePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return ""
+	}
+	return rctx.RoutePattern()
+}
+
+// metricMethod - normalizes the method label, clients can send any method
+// and each would otherwise create new series
+func metricMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "other"
+}
+
+// begin - counts a request in flight
+func (m *metrics) begin() {
+	atomic.AddInt64(&m.inFlight, 1)
+}
+
+// abort - drops a request aborted with http.ErrAbortHandler, it has no
+// status to be counted with
+func (m *metrics) abort() {
+	atomic.AddInt64(&m.inFlight, -1)
+}
+
+// end - records a finished request
+func (m *metrics) end(method string, route string, status int, size int, d time.Duration) {
+	atomic.AddInt64(&m.inFlight, -1)
+	rk := routeKey{method: metricMethod(method), route: route}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[requestKey{routeKey: rk, status: status}]++
+	observe(m.durations, rk, m.durationB, d.Seconds())
+	observe(m.sizes, rk, m.sizeB, float64(size))
+}
+
+func observe(series map[routeKey]*histogram, rk routeKey, buckets []float64, v float64) {
+	h, ok := series[rk]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(buckets))}
+		series[rk] = h
+	}
+	for i, b := range buckets {
+		if v <= b {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// ServeHTTP - writes the metrics in the prometheus text exposition format
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var b strings.Builder
+	m.mu.Lock()
+	b.WriteString("# HELP http_requests_total Total number of http requests.\n")
+	b.WriteString("# TYPE http_requests_total counter\n")
+	keys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].routeKey != keys[j].routeKey {
+			return keys[i].routeKey.less(keys[j].routeKey)
+		}
+		return keys[i].status < keys[j].status
+	})
+	for _, k := range keys {
+		b.WriteString("http_requests_total{" + k.labels() + `,status="` + strconv.Itoa(k.status) + `"} `)
+		b.WriteString(strconv.FormatUint(m.requests[k], 10) + "\n")
+	}
+	writeHistogram(&b, "http_request_duration_seconds", "Duration of http requests in seconds.", m.durations, m.durationB)
+	writeHistogram(&b, "http_response_size_bytes", "Size of http responses in bytes.", m.sizes, m.sizeB)
+	m.mu.Unlock()
+	b.WriteString("# HELP http_requests_in_flight Number of http requests being served.\n")
+	b.WriteString("# TYPE http_requests_in_flight gauge\n")
+	b.WriteString("http_requests_in_flight " + strconv.FormatInt(atomic.LoadInt64(&m.inFlight), 10) + "\n")
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
+
+func writeHistogram(b *strings.Builder, name string, help string, series map[routeKey]*histogram, buckets []float64) {
+	b.WriteString("# HELP " + name + " " + help + "\n")
+	b.WriteString("# TYPE " + name + " histogram\n")
+	keys := make([]routeKey, 0, len(series))
+	for k := range series {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].less(keys[j]) })
+	for _, k := range keys {
+		h := series[k]
+		labels := k.labels()
+		var cumulative uint64
+		for i, le := range buckets {
+			cumulative += h.counts[i]
+			b.WriteString(name + "_bucket{" + labels + `,le="` + formatFloat(le) + `"} ` + strconv.FormatUint(cumulative, 10) + "\n")
+		}
+		b.WriteString(name + "_bucket{" + labels + `,le="+Inf"} ` + strconv.FormatUint(h.count, 10) + "\n")
+		b.WriteString(name + "_sum{" + labels + "} " + formatFloat(h.sum) + "\n")
+		b.WriteString(name + "_count{" + labels + "} " + strconv.FormatUint(h.count, 10) + "\n")
+	}
+}
+
+func (k routeKey) less(o routeKey) bool {
+	if k.route != o.route {
+		return k.route < o.route
+	}
+	return k.method < o.method
+}
+
+func (k routeKey) labels() string {
+	return `method="` + escapeLabel(k.method) + `",route="` + escapeLabel(k.route) + `"`
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
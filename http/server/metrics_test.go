@@ -0,0 +1,93 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	router, _ := New(nil, nil)
+	router.SetLogger("empty")
+	router.Metrics("/metrics", []float64{0.1, 1})
+	router.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	router.Get("/crash", func(w http.ResponseWriter, r *http.Request) {
+		panic(errors.New("deliberate crash"))
+	})
+	for _, path := range []string{"/users/1", "/users/2", "/crash", "/nothing"} {
+		router.Engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	for _, method := range []string{"BREW", "WHEN"} {
+		router.Engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/users/1", nil))
+	}
+
+	res := httptest.NewRecorder()
+	router.Engine.ServeHTTP(res, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.HasPrefix(res.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", res.Header().Get("Content-Type"))
+	}
+	b, _ := io.ReadAll(res.Body)
+	body := string(b)
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="/users/{id}",status="200"} 2`,
+		`http_requests_total{method="GET",route="/crash",status="500"} 1`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		// unknown methods share one label
+		`http_requests_total{method="other",route="unmatched",status="405"} 2`,
+		`http_request_duration_seconds_bucket{method="GET",route="/users/{id}",le="+Inf"} 2`,
+		`http_request_duration_seconds_count{method="GET",route="/users/{id}"} 2`,
+		`http_response_size_bytes_bucket{method="GET",route="/users/{id}",le="100"} 2`,
+		`http_response_size_bytes_sum{method="GET",route="/users/{id}"} 10`,
+		"# TYPE http_request_duration_seconds histogram",
+		// the scrape itself is in flight
+		"http_requests_in_flight 1",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %s in\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "/users/1") || strings.Contains(body, "BREW") {
+		t.Error("raw paths and methods should not be used as labels")
+	}
+}
+
+func TestMetricsBuckets(t *testing.T) {
+	router, _ := New(nil, nil)
+	router.SetLogger("empty")
+	buckets := []float64{1, 0.1, math.Inf(1), 0.5, 0.1, math.NaN()}
+	router.Metrics("/metrics", buckets)
+	if got := router.metrics.durationB; len(got) != 3 || got[0] != 0.1 || got[1] != 0.5 || got[2] != 1 {
+		t.Errorf("expected sorted unique buckets, got %v", got)
+	}
+	if buckets[0] != 1 {
+		t.Error("the buckets of the caller should not be modified")
+	}
+	router.metrics.end("GET", "/", 200, 0, 300*time.Millisecond)
+	res := httptest.NewRecorder()
+	router.Engine.ServeHTTP(res, httptest.NewRequest("GET", "/metrics", nil))
+	for _, want := range []string{`le="0.1"} 0`, `le="0.5"} 1`, `le="1"} 1`, `le="+Inf"} 1`} {
+		if !strings.Contains(res.Body.String(), `http_request_duration_seconds_bucket{method="GET",route="/",`+want) {
+			t.Errorf("missing %s in\n%s", want, res.Body)
+		}
+	}
+
+	router.Metrics("/metrics2", nil)
+	router.metrics.durationB[0] = 42
+	if DurationBuckets[0] == 42 {
+		t.Error("DurationBuckets should not be shared")
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	if v := escapeLabel("a\"b\\c\nd"); v != `a\"b\\c\nd` {
+		t.Errorf("unexpected escaping %s", v)
+	}
+}
@@ -1,52 +1,109 @@
 package server
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
-	"strconv"
+	"runtime/debug"
 	"time"
 
+	"github.com/kelchy/go-lib/log"
 	"github.com/urfave/negroni"
 )
 
+// catchall - outermost middleware, sets up the request context, recovers
+// panics of every other middleware and handler, records metrics and writes
+// the access log
 func (rtr *Router) catchall(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		t1 := time.Now()
 		w2 := negroni.NewResponseWriter(w)
+		if rtr.metrics != nil {
+			rtr.metrics.begin()
+		}
 		// defer is first in last out, this will run if in case any
 		// uncaught panic happens within the api logic, except if
 		// it happens within another go routine created within
 		defer func() {
 			rc := recover()
-			diff := float64(time.Since(t1).Microseconds()) / 1000
-			diffStr := fmt.Sprintf("%f", diff)
+			if rc == http.ErrAbortHandler {
+				if rtr.metrics != nil {
+					rtr.metrics.abort()
+				}
+				// let net/http abort the response without logging
+				panic(rc)
+			}
 			if rc != nil {
-				rtr.log.Error("HTTPS_MW", errors.New("Uncaught Exception: "+rc.(error).Error()))
-				// build generic 500 error
-				jsonBody, _ := json.Marshal(map[string]string{
-					"error": "There was an internal server error",
-				})
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusInternalServerError)
-				w.Write(jsonBody)
-			} else if rtr.logRequest {
+				rtr.recovered(w2, r, rc, debug.Stack())
+			}
+			d := time.Since(t1)
+			status := w2.Status()
+			if status == 0 && rc != nil {
+				status = http.StatusInternalServerError
+			} else if status == 0 {
+				// nothing written, net/http answers 200
+				status = http.StatusOK
+			}
+			if rtr.metrics != nil {
+				rtr.metrics.end(r.Method, route(r), status, w2.Size(), d)
+			}
+			// panics are logged by recovered
+			if rc == nil && rtr.logRequest {
 				if !contains(rtr.logSkipPath, r.URL.Path) {
-					msg, _ := json.Marshal(map[string]string{
-						"method": r.Method,
-						"status": strconv.Itoa(w2.Status()),
-						"src":    r.RemoteAddr,
-						"ms":     diffStr,
-					})
-					rtr.log.Out(r.URL.Path, string(msg))
+					// request_id, trace_id and span_id are added as fields
+					rtr.log.Ctx(r.Context()).OutFields(r.URL.Path, "request",
+						log.String("method", r.Method),
+						log.Int("status", status),
+						log.String("src", r.RemoteAddr),
+						log.Float("ms", float64(d.Microseconds())/1000))
 				}
 			}
 		}()
+		// the deferred function sees the request with ids, the remote
+		// address set by RealIP further down is kept as it is the same request
+		r = rtr.requestContext(w2, r)
 		next.ServeHTTP(w2, r)
 	})
 }
 
+// PanicHook - called with the request, the value passed to panic and the
+// stack of the panicking goroutine, e.g. to report panics to an error tracker
+type PanicHook func(r *http.Request, rc interface{}, stack []byte)
+
+// OnPanic - sets a hook called when a handler panics
+func (rtr *Router) OnPanic(hook PanicHook) {
+	rtr.panicHook = hook
+}
+
+// recovered - logs a recovered panic, calls the hook and answers with 500
+// unless the handler already started the response
+func (rtr *Router) recovered(w negroni.ResponseWriter, r *http.Request, rc interface{}, stack []byte) {
+	if rc == http.ErrAbortHandler {
+		return
+	}
+	err, ok := rc.(error)
+	if !ok {
+		err = fmt.Errorf("%v", rc)
+	}
+	rtr.log.Ctx(r.Context()).Error("HTTPS_MW", errors.New("Uncaught Exception: "+err.Error()))
+	if rtr.panicHook != nil {
+		func() {
+			// a failing hook must not take the server down
+			defer func() {
+				if hc := recover(); hc != nil {
+					rtr.log.Error("HTTPS_MW", fmt.Errorf("Panic hook failed: %v", hc))
+				}
+			}()
+			rtr.panicHook(r, rc, stack)
+		}()
+	}
+	if w.Written() {
+		// status and headers are already sent, the client sees a truncated body
+		return
+	}
+	WriteError(w, r, NewError(http.StatusInternalServerError, "internal", "There was an internal server error").Wrap(err))
+}
+
 func contains(s []string, str string) bool {
 	for _, v := range s {
 		if v == str {
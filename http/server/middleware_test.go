@@ -0,0 +1,120 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCatchallPanics(t *testing.T) {
+	router, _ := New(nil, nil)
+	router.SetLogger("empty")
+	var hooked []interface{}
+	var stack string
+	router.OnPanic(func(r *http.Request, rc interface{}, s []byte) {
+		hooked = append(hooked, rc)
+		stack = string(s)
+	})
+	router.Get("/string", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	router.Get("/int", func(w http.ResponseWriter, r *http.Request) {
+		panic(42)
+	})
+	router.Get("/error", func(w http.ResponseWriter, r *http.Request) {
+		panic(errors.New("deliberate crash"))
+	})
+	router.Get("/started", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("partial"))
+		panic("late")
+	})
+
+	for _, path := range []string{"/string", "/int", "/error"} {
+		res := httptest.NewRecorder()
+		router.Engine.ServeHTTP(res, httptest.NewRequest("GET", path, nil))
+		if res.Code != http.StatusInternalServerError || res.Header().Get("Content-Type") != ProblemContentType {
+			t.Errorf("%s: expected a 500 problem, got %d %s", path, res.Code, res.Header().Get("Content-Type"))
+		}
+		if strings.Contains(res.Body.String(), "boom") {
+			t.Errorf("%s: panic value leaked %s", path, res.Body)
+		}
+	}
+	if len(hooked) != 3 || hooked[0] != "boom" || hooked[1] != 42 {
+		t.Errorf("hook should receive every panic value, got %v", hooked)
+	}
+	if !strings.Contains(stack, "middleware_test.go") {
+		t.Errorf("stack should include the panicking handler, got %s", stack)
+	}
+
+	// a started response is left alone
+	res := httptest.NewRecorder()
+	router.Engine.ServeHTTP(res, httptest.NewRequest("GET", "/started", nil))
+	if res.Code != http.StatusAccepted || res.Body.String() != "partial" {
+		t.Errorf("started response should not be rewritten, got %d %q", res.Code, res.Body)
+	}
+}
+
+func TestCatchallPanicHookFails(t *testing.T) {
+	router, _ := New(nil, nil)
+	router.SetLogger("empty")
+	router.OnPanic(func(r *http.Request, rc interface{}, s []byte) {
+		panic("hook broken")
+	})
+	router.Get("/crash", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	res := httptest.NewRecorder()
+	router.Engine.ServeHTTP(res, httptest.NewRequest("GET", "/crash", nil))
+	if res.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", res.Code)
+	}
+}
+
+func TestCatchallAbortHandler(t *testing.T) {
+	router, _ := New(nil, nil)
+	router.SetLogger("empty")
+	hooked := false
+	router.OnPanic(func(r *http.Request, rc interface{}, s []byte) {
+		hooked = true
+	})
+	router.Metrics("/metrics", nil)
+	router.Get("/abort", func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+	srv := httptest.NewServer(router.Engine)
+	defer srv.Close()
+	if res, err := http.Get(srv.URL + "/abort"); err == nil {
+		res.Body.Close()
+		t.Errorf("expected the connection to be aborted, got %d", res.StatusCode)
+	}
+	if hooked {
+		t.Error("aborts are not panics to report")
+	}
+	// close waits for the handler to return
+	srv.Close()
+	router.metrics.mu.Lock()
+	defer router.metrics.mu.Unlock()
+	if n, inFlight := len(router.metrics.requests), atomic.LoadInt64(&router.metrics.inFlight); n != 0 || inFlight != 0 {
+		t.Errorf("aborts should not be recorded, got %d series and %d in flight", n, inFlight)
+	}
+}
+
+func TestCatchallMiddlewarePanics(t *testing.T) {
+	router, _ := New(nil, nil)
+	router.SetLogger("empty")
+	router.Engine.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("middleware broken")
+		})
+	})
+	router.Get("/", func(w http.ResponseWriter, r *http.Request) {})
+	res := httptest.NewRecorder()
+	router.Engine.ServeHTTP(res, httptest.NewRequest("GET", "/", nil))
+	if res.Code != http.StatusInternalServerError || res.Header().Get(RequestIDHeader) == "" {
+		t.Errorf("expected a 500 with a request id, got %d %v", res.Code, res.Header())
+	}
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// ProblemContentType - media type of RFC 7807 error responses
+const ProblemContentType = "application/problem+json"
+
+// Error - api error written as an RFC 7807 problem, Code is a stable
+// machine readable identifier clients can switch on
+type Error struct {
+	Status int
+	Code   string
+	Detail string
+	// Type - uri identifying the problem type, defaults to about:blank
+	Type string
+	// Extra - extension members added to the response, e.g. invalid fields
+	Extra map[string]interface{}
+	// Err - cause of the error, logged but never sent to clients
+	Err error
+}
+
+// NewError - creates an api error
+func NewError(status int, code string, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+// Error - describes the error with its cause
+func (e *Error) Error() string {
+	msg := e.Code + ": " + e.Detail
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap - returns the cause
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// With - returns a copy of the error with an extension member
+func (e *Error) With(key string, value interface{}) *Error {
+	c := *e
+	c.Extra = make(map[string]interface{}, len(e.Extra)+1)
+	for k, v := range e.Extra {
+		c.Extra[k] = v
+	}
+	c.Extra[key] = value
+	return &c
+}
+
+// Wrap - returns a copy of the error caused by err
+func (e *Error) Wrap(err error) *Error {
+	c := *e
+	c.Err = err
+	return &c
+}
+
+// mapping - error mapped to an api error by MapError
+type mapping struct {
+	target error
+	status int
+	code   string
+	detail string
+}
+
+var (
+	mappingsMu sync.RWMutex
+	mappings   []mapping
+)
+
+// errNoDocuments - message of mongo.ErrNoDocuments, compared by text so
+// the server does not depend on the mongo driver, this breaks if the driver
+// ever changes the message, applications can MapError(mongo.ErrNoDocuments,
+// ...) to match the sentinel itself as mappings are checked first
+const errNoDocuments = "mongo: no documents in result"
+
+// MapError - makes errors matching target (with errors.Is) answer with
+// status, code and detail, e.g. MapError(redis.Nil, 404, "not_found", ""),
+// the message of the error is never sent as it may contain internals, an
+// empty detail uses the status text
+func MapError(target error, status int, code string, detail string) {
+	if detail == "" {
+		detail = http.StatusText(status)
+	}
+	mappingsMu.Lock()
+	mappings = append(mappings, mapping{target: target, status: status, code: code, detail: detail})
+	mappingsMu.Unlock()
+}
+
+// ResetErrorMappings - removes every mapping added by MapError, e.g. between
+// tests
+func ResetErrorMappings() {
+	mappingsMu.Lock()
+	mappings = nil
+	mappingsMu.Unlock()
+}
+
+// ToError - converts err to an api error:
+//
+//	*Error                    - as is
+//	errors given to MapError  - their status, code and detail
+//	*ValidationError          - 400 validation_failed with the fields
+//	request body too large    - 413 body_too_large
+//	*DecodeError              - 400 invalid_request
+//	mongo.ErrNoDocuments      - 404 not_found
+//	context.DeadlineExceeded  - 504 timeout
+//	context.Canceled          - 499 canceled
+//	anything else             - 500 internal, with the cause hidden
+func ToError(err error) *Error {
+	var ae *Error
+	if errors.As(err, &ae) {
+		return ae
+	}
+	if ae := mapped(err); ae != nil {
+		return ae
+	}
+	var ve *ValidationError
+	if errors.As(err, &ve) {
+		return NewError(http.StatusBadRequest, "validation_failed", ve.Error()).With("fields", ve.Fields).Wrap(err)
+	}
+	// checked before decode errors as decoding a body cut by the limit fails
+	if isBodyTooLarge(err) {
+		return NewError(http.StatusRequestEntityTooLarge, "body_too_large", "Request body too large").Wrap(err)
+	}
+	var de *DecodeError
+	if errors.As(err, &de) {
+		return NewError(http.StatusBadRequest, "invalid_request", de.Error()).Wrap(err)
+	}
+	if hasMessage(err, errNoDocuments) {
+		return NewError(http.StatusNotFound, "not_found", "Resource not found").Wrap(err)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return NewError(http.StatusGatewayTimeout, "timeout", "The request timed out").Wrap(err)
+	}
+	if errors.Is(err, context.Canceled) {
+		// nginx convention for a client that went away
+		return NewError(499, "canceled", "The request was canceled").Wrap(err)
+	}
+	return NewError(http.StatusInternalServerError, "internal", "There was an internal server error").Wrap(err)
+}
+
+// mapped - returns the api error of the first mapping matching err or nil
+func mapped(err error) *Error {
+	mappingsMu.RLock()
+	defer mappingsMu.RUnlock()
+	for _, m := range mappings {
+		if errors.Is(err, m.target) {
+			return NewError(m.status, m.code, m.detail).Wrap(err)
+		}
+	}
+	return nil
+}
+
+// hasMessage - true if err or an error it wraps has the message msg
+func hasMessage(err error, msg string) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if err.Error() == msg {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteError - writes err converted with ToError as application/problem+json,
+// error mirrors detail for clients of the previous {"error": ...} body
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	ae := ToError(err)
+	status := ae.Status
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	title := http.StatusText(status)
+	if title == "" {
+		title = ae.Code
+	}
+	body := make(map[string]interface{}, len(ae.Extra)+8)
+	for k, v := range ae.Extra {
+		body[k] = v
+	}
+	body["type"] = "about:blank"
+	if ae.Type != "" {
+		body["type"] = ae.Type
+	}
+	body["title"] = title
+	body["status"] = status
+	body["detail"] = ae.Detail
+	body["code"] = ae.Code
+	body["error"] = ae.Detail
+	if r != nil {
+		body["instance"] = r.URL.Path
+		if id := RequestID(r.Context()); id != "" {
+			body["request_id"] = id
+		}
+	}
+	b, _ := json.Marshal(body)
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(status)
+	w.Write(b)
+}
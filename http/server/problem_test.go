@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var errLocked = errors.New("account locked")
+
+func TestToError(t *testing.T) {
+	t.Cleanup(ResetErrorMappings)
+	MapError(errLocked, http.StatusLocked, "locked", "")
+	tooLarge := func() error {
+		_, err := io.ReadAll(http.MaxBytesReader(httptest.NewRecorder(), io.NopCloser(strings.NewReader("too large")), 2))
+		return err
+	}
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{NewError(http.StatusConflict, "duplicate", "Order exists"), http.StatusConflict, "duplicate"},
+		{fmt.Errorf("find: %w", errors.New(errNoDocuments)), http.StatusNotFound, "not_found"},
+		{fmt.Errorf("query: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, "timeout"},
+		{context.Canceled, 499, "canceled"},
+		{&ValidationError{Fields: []FieldError{{Field: "qty"}}}, http.StatusBadRequest, "validation_failed"},
+		{&DecodeError{Err: errors.New("bad")}, http.StatusBadRequest, "invalid_request"},
+		{tooLarge(), http.StatusRequestEntityTooLarge, "body_too_large"},
+		{&DecodeError{Err: tooLarge()}, http.StatusRequestEntityTooLarge, "body_too_large"},
+		{fmt.Errorf("login: %w", errLocked), http.StatusLocked, "locked"},
+		{errors.New("boom"), http.StatusInternalServerError, "internal"},
+	}
+	for _, tt := range tests {
+		ae := ToError(tt.err)
+		if ae.Status != tt.status || ae.Code != tt.code {
+			t.Errorf("%v: expected %d %s, got %d %s", tt.err, tt.status, tt.code, ae.Status, ae.Code)
+		}
+	}
+
+	// mapped errors do not leak the message of the wrapped chain
+	if ae := ToError(fmt.Errorf("login user@example.com: %w", errLocked)); ae.Detail != http.StatusText(http.StatusLocked) {
+		t.Errorf("unexpected detail %q", ae.Detail)
+	}
+	MapError(errLocked, http.StatusForbidden, "locked", "Account locked")
+	ResetErrorMappings()
+	if ae := ToError(errLocked); ae.Status != http.StatusInternalServerError {
+		t.Errorf("mappings should be reset, got %d", ae.Status)
+	}
+}
+
+func TestHandleBodyTooLarge(t *testing.T) {
+	router, _ := New(nil, nil)
+	router.SetLogger("empty")
+	router.SetConfig(Config{MaxBodyBytes: 16})
+	router.Post("/orders", Handle(func(ctx context.Context, req createOrder) (order, error) {
+		return order{}, nil
+	}))
+	// a chunked body has no content length and is cut while decoding
+	req := httptest.NewRequest("POST", "/orders", io.MultiReader(strings.NewReader(`{"item":"`), strings.NewReader(strings.Repeat("a", 64)+`"}`)))
+	req.ContentLength = -1
+	req.TransferEncoding = []string{"chunked"}
+	res := httptest.NewRecorder()
+	router.Engine.ServeHTTP(res, req)
+	if res.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %d %s", res.Code, res.Body)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	router, _ := New(nil, nil)
+	router.SetLogger("empty")
+	router.Get("/orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, r, NewError(http.StatusConflict, "duplicate", "Order exists").With("order_id", URLParam(r, "id")))
+	})
+	router.Get("/boom", func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, r, errors.New("password=hunter2"))
+	})
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/orders/42", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	router.Engine.ServeHTTP(res, req)
+	if res.Code != http.StatusConflict || res.Header().Get("Content-Type") != ProblemContentType {
+		t.Fatalf("unexpected response %d %s", res.Code, res.Header().Get("Content-Type"))
+	}
+	var body map[string]interface{}
+	json.Unmarshal(res.Body.Bytes(), &body)
+	want := map[string]interface{}{
+		"type": "about:blank", "title": "Conflict", "status": float64(409), "detail": "Order exists",
+		"code": "duplicate", "instance": "/orders/42", "order_id": "42", "request_id": "req-1",
+	}
+	for k, v := range want {
+		if body[k] != v {
+			t.Errorf("%s: expected %v, got %v", k, v, body[k])
+		}
+	}
+
+	// causes of server errors are not leaked
+	res = httptest.NewRecorder()
+	router.Engine.ServeHTTP(res, httptest.NewRequest("GET", "/boom", nil))
+	if res.Code != http.StatusInternalServerError || strings.Contains(res.Body.String(), "hunter2") {
+		t.Errorf("unexpected response %d %s", res.Code, res.Body)
+	}
+}
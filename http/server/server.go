@@ -1,15 +1,17 @@
 package server
 
 import (
+	"crypto/tls"
 	"errors"
+	"net"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/cors"
 	"github.com/kelchy/go-lib/log"
 	"golang.org/x/net/http2"
-	"golang.org/x/net/http2/h2c"
 )
 
 // ChiRouter - interface for chi router
@@ -21,6 +23,14 @@ type Router struct {
 	log			log.Log
 	logRequest		bool
 	logSkipPath		[]string
+	lifecycle		*lifecycle
+	shutdownTimeout		time.Duration
+	shutdownDelay		time.Duration
+	shutdownHookTimeout	time.Duration
+	http2			HTTP2Config
+	limits			*limits
+	metrics			*metrics
+	panicHook		PanicHook
 }
 
 // New - constructor function to initialize instance
@@ -33,6 +43,9 @@ func New(origins []string, headers []string) (*Router, error) {
 	}
 	rtr.log = l
 	rtr.logRequest = true
+	rtr.lifecycle = &lifecycle{}
+	rtr.limits = &limits{}
+	rtr.shutdownTimeout = ShutdownTimeout
 
 	// by default middleware don't log root path which is
 	// usually used by health checks
@@ -41,23 +54,25 @@ func New(origins []string, headers []string) (*Router, error) {
 	if len(origins) == 0 {
 		origins = []string{"http://localhost", "https://localhost"}
 	}
-	allowedDefault := []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"}
+	allowedDefault := []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", RequestIDHeader, TraceparentHeader, TracestateHeader}
 	if len(headers) == 0 {
 		headers = allowedDefault
 	} else {
 		headers = append(headers, allowedDefault...)
 	}
 	rtr.Engine = chi.NewRouter()
+	// outermost so panics of the other middlewares are recovered too
+	rtr.Engine.Use(rtr.catchall)
 	rtr.Engine.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   origins,
 		AllowedMethods:   []string{ "GET", "POST", "PUT", "DELETE", "OPTIONS" },
 		AllowedHeaders:   headers,
-		ExposedHeaders:   []string{"Link"},
+		ExposedHeaders:   []string{"Link", RequestIDHeader, TraceparentHeader, TracestateHeader},
 		AllowCredentials: true,
 		MaxAge:           12 * 60 * 60,
 	}))
 	rtr.Engine.Use(middleware.RealIP)
-	rtr.Engine.Use(rtr.catchall)
+	rtr.Engine.Use(rtr.limitBody)
 	return &rtr, nil
 }
 
@@ -69,6 +84,12 @@ func (rtr *Router) SetLogger(logtype string) {
 	}
 }
 
+// SetLog - replaces the logger, e.g. with one from log.NewWithOptions
+// writing elsewhere or with another level
+func (rtr *Router) SetLog(l log.Log) {
+	rtr.log = l
+}
+
 // SetLogSkipPath - changes the middleware logging behaviour
 func (rtr *Router) SetLogSkipPath(list []string) {
 	rtr.logSkipPath = list
@@ -79,18 +100,23 @@ func (rtr *Router) SetLogRequest(lr bool) {
 	rtr.logRequest = lr
 }
 
+// serving - called with the server started by Run, RunS and RunTLS before
+// it accepts connections, replaced in tests to close it
+var serving = func(srv *http.Server) {}
+
 // Run - run and listen for http
 func (rtr Router) Run(proto string, hostport string) error {
 	rtr.log.Out("SERVER_RUN", "Listening "+proto+" "+hostport)
-	var e error
-	if proto == "http" {
-		e = http.ListenAndServe(hostport, rtr.Engine)
-	} else if proto == "h2c" {
-		// h2c denotes http/2 in cleartext, useful in cases where API GW strips encryption
-		h2s := &http2.Server{}
-		http.ListenAndServe(hostport, h2c.NewHandler(rtr.Engine, h2s))
-	} else {
-		e = errors.New("Unknown Proto")
+	handler, e := rtr.handler(proto)
+	cfg := rtr.limits.get()
+	var ln net.Listener
+	if e == nil {
+		ln, e = listen(hostport, cfg)
+	}
+	if e == nil {
+		srv := httpServer(hostport, handler, cfg)
+		serving(srv)
+		e = srv.Serve(ln)
 	}
 	if e != nil {
 		rtr.log.Error("SERVER_RUN", e)
@@ -98,16 +124,19 @@ func (rtr Router) Run(proto string, hostport string) error {
 	return e
 }
 
-// RunS - run and listen for https
+// RunS - run and listen for https, h2 serves http/2 negotiated with ALPN
+// and falls back to http/1.1 for clients without http/2 support
 func (rtr Router) RunS(proto string, hostport string, crt string, key string) error {
 	rtr.log.Out("SERVER_RUNS", "Listening "+proto+" "+hostport)
-	var e error
-	if proto == "https" {
-		e = http.ListenAndServeTLS(hostport, crt, key, rtr.Engine)
-	} else if proto == "h2" {
-		// TODO: add h2
-	} else {
-		e = errors.New("Unknown Proto")
+	cfg := rtr.limits.get()
+	srv, e := rtr.tlsServer(proto, hostport, &tls.Config{MinVersion: tls.VersionTLS12}, cfg)
+	var ln net.Listener
+	if e == nil {
+		ln, e = listen(hostport, cfg)
+	}
+	if e == nil {
+		serving(srv)
+		e = srv.ServeTLS(ln, crt, key)
 	}
 	if e != nil {
 		rtr.log.Error("SERVER_RUNS", e)
@@ -115,6 +144,22 @@ func (rtr Router) RunS(proto string, hostport string, crt string, key string) er
 	return e
 }
 
+// tlsServer - returns the server for proto over tls
+func (rtr Router) tlsServer(proto string, hostport string, tc *tls.Config, cfg Config) (*http.Server, error) {
+	srv := httpServer(hostport, rtr.Engine, cfg)
+	srv.TLSConfig = tc
+	if proto == "https" {
+		return srv, nil
+	} else if proto == "h2" {
+		tc.NextProtos = []string{http2.NextProtoTLS, "http/1.1"}
+		if e := http2.ConfigureServer(srv, rtr.h2server()); e != nil {
+			return nil, e
+		}
+		return srv, nil
+	}
+	return nil, errors.New("Unknown Proto")
+}
+
 // Static - function to handle and serve static files within a directory on live system
 func (rtr Router) Static(urlPath string, dirPath string) {
 	// do not use wildcard (*) in urlPath
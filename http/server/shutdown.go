@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/http2/h2c"
+)
+
+// ShutdownTimeout - default time given to in-flight requests once the
+// server stops
+const ShutdownTimeout = 30 * time.Second
+
+// ShutdownHookTimeout - default time given to the shutdown hooks once
+// requests are drained
+const ShutdownHookTimeout = 10 * time.Second
+
+// lifecycle - readiness and shutdown hooks shared by copies of the router
+type lifecycle struct {
+	mu       sync.Mutex
+	draining bool
+	hooks    []shutdownHook
+}
+
+type shutdownHook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// OnShutdown - registers a function run after in-flight requests are
+// drained, e.g. closing mongo, redis or rmq clients, hooks run in reverse
+// order of registration and share their own deadline, see
+// SetShutdownHookTimeout
+func (rtr *Router) OnShutdown(name string, fn func(ctx context.Context) error) {
+	rtr.lifecycle.mu.Lock()
+	rtr.lifecycle.hooks = append(rtr.lifecycle.hooks, shutdownHook{name: name, fn: fn})
+	rtr.lifecycle.mu.Unlock()
+}
+
+// SetShutdownTimeout - changes how long in-flight requests are given
+// before connections are closed
+func (rtr *Router) SetShutdownTimeout(d time.Duration) {
+	rtr.shutdownTimeout = d
+}
+
+// SetShutdownHookTimeout - changes how long the shutdown hooks are given
+// after requests are drained
+func (rtr *Router) SetShutdownHookTimeout(d time.Duration) {
+	rtr.shutdownHookTimeout = d
+}
+
+// SetShutdownDelay - changes how long the server keeps serving after it
+// reports not ready, giving load balancers time to stop sending traffic
+func (rtr *Router) SetShutdownDelay(d time.Duration) {
+	rtr.shutdownDelay = d
+}
+
+// Ready - returns false once the server started shutting down
+func (rtr Router) Ready() bool {
+	rtr.lifecycle.mu.Lock()
+	defer rtr.lifecycle.mu.Unlock()
+	return !rtr.lifecycle.draining
+}
+
+// Serve - run and listen for http like Run until ctx is cancelled or the
+// process receives SIGTERM or SIGINT, then readiness fails, in-flight
+// requests are drained and shutdown hooks run, returns nil on a clean
+// shutdown
+func (rtr Router) Serve(ctx context.Context, proto string, hostport string) error {
+	handler, e := rtr.handler(proto)
+	if e != nil {
+		rtr.log.Error("SERVER_SERVE", e)
+		return e
+	}
+	rtr.limits.enable()
+	cfg := rtr.limits.get()
+	ln, e := listen(hostport, cfg)
+	if e != nil {
+		rtr.log.Error("SERVER_SERVE", e)
+		return e
+	}
+	rtr.log.Out("SERVER_SERVE", "Listening "+proto+" "+hostport)
+	srv := httpServer(hostport, handler, cfg)
+	return rtr.serve(ctx, srv, func() error {
+		return srv.Serve(ln)
+	})
+}
+
+// handler - returns the handler serving proto over cleartext
+func (rtr Router) handler(proto string) (http.Handler, error) {
+	if proto == "http" {
+		return rtr.Engine, nil
+	} else if proto == "h2c" {
+		// h2c denotes http/2 in cleartext, useful in cases where API GW strips encryption
+		return h2c.NewHandler(rtr.Engine, rtr.h2server()), nil
+	}
+	return nil, errors.New("Unknown Proto")
+}
+
+// serve - runs listen until it fails or a shutdown is triggered
+func (rtr Router) serve(ctx context.Context, srv *http.Server, listen func() error) error {
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- listen()
+	}()
+	select {
+	case e := <-errc:
+		if e != nil && !errors.Is(e, http.ErrServerClosed) {
+			rtr.log.Error("SERVER_SERVE", e)
+			return e
+		}
+		return nil
+	case <-ctx.Done():
+	}
+	// restore default signal handling so a second signal kills a stuck shutdown
+	stop()
+	return rtr.shutdown(srv)
+}
+
+// shutdown - fails readiness, waits for the shutdown delay, drains in-flight
+// requests within the shutdown timeout and runs the hooks within the hook
+// timeout
+func (rtr Router) shutdown(srv *http.Server) error {
+	rtr.lifecycle.mu.Lock()
+	rtr.lifecycle.draining = true
+	hooks := make([]shutdownHook, len(rtr.lifecycle.hooks))
+	copy(hooks, rtr.lifecycle.hooks)
+	rtr.lifecycle.mu.Unlock()
+
+	rtr.log.Out("SERVER_SHUTDOWN", "Shutting down, draining requests")
+	if rtr.shutdownDelay > 0 {
+		time.Sleep(rtr.shutdownDelay)
+	}
+	timeout := rtr.shutdownTimeout
+	if timeout <= 0 {
+		timeout = ShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := srv.Shutdown(ctx)
+	if err != nil {
+		// deadline reached, remaining connections are closed forcibly
+		rtr.log.Error("SERVER_SHUTDOWN", err)
+		srv.Close()
+	}
+	hookTimeout := rtr.shutdownHookTimeout
+	if hookTimeout <= 0 {
+		hookTimeout = ShutdownHookTimeout
+	}
+	// the drain may have used up its deadline, hooks get their own
+	hookCtx, hookCancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer hookCancel()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if e := hooks[i].fn(hookCtx); e != nil {
+			rtr.log.Error("SERVER_SHUTDOWN", errors.New(hooks[i].name+": "+e.Error()))
+			if err == nil {
+				err = e
+			}
+		}
+	}
+	rtr.log.Out("SERVER_SHUTDOWN", "Shutdown complete")
+	return err
+}
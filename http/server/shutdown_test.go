@@ -0,0 +1,171 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// freeAddr - returns a local address nothing listens on
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+// waitListening - waits until addr accepts connections
+func waitListening(t *testing.T, addr string) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if c, err := net.Dial("tcp", addr); err == nil {
+			c.Close()
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%s is not listening", addr)
+}
+
+// start - runs a server with run on a free address until the test ends,
+// run must call Run, RunS or RunTLS with the address
+func start(t *testing.T, run func(addr string) error) string {
+	t.Helper()
+	orig := serving
+	started := make(chan *http.Server, 1)
+	serving = func(srv *http.Server) {
+		started <- srv
+	}
+	defer func() { serving = orig }()
+	addr := freeAddr(t)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- run(addr)
+	}()
+	select {
+	case srv := <-started:
+		t.Cleanup(func() {
+			srv.Close()
+			<-errc
+		})
+	case err := <-errc:
+		t.Fatalf("server did not start: %v", err)
+	}
+	waitListening(t, addr)
+	return addr
+}
+
+func TestServeGracefulShutdown(t *testing.T) {
+	router, _ := New(nil, nil)
+	router.SetLogger("empty")
+	router.SetShutdownTimeout(2 * time.Second)
+	started := make(chan struct{})
+	router.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("done"))
+	})
+	var order []string
+	router.OnShutdown("mongo", func(ctx context.Context) error {
+		order = append(order, "mongo")
+		return nil
+	})
+	router.OnShutdown("redis", func(ctx context.Context) error {
+		order = append(order, "redis")
+		return errors.New("close failed")
+	})
+
+	addr := freeAddr(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- router.Serve(ctx, "http", addr)
+	}()
+	waitListening(t, addr)
+	if !router.Ready() {
+		t.Fatal("router should be ready while serving")
+	}
+
+	body := make(chan string, 1)
+	go func() {
+		res, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		b, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		body <- string(b)
+	}()
+	<-started
+	cancel()
+
+	if b := <-body; b != "done" {
+		t.Errorf("in-flight request should complete, got %q", b)
+	}
+	if err := <-served; err == nil || err.Error() != "close failed" {
+		t.Errorf("expected hook error, got %v", err)
+	}
+	if router.Ready() {
+		t.Error("router should not be ready after shutdown")
+	}
+	if len(order) != 2 || order[0] != "redis" || order[1] != "mongo" {
+		t.Errorf("hooks should run in reverse order, got %v", order)
+	}
+	if _, err := net.Dial("tcp", addr); err == nil {
+		t.Error("listener should be closed")
+	}
+
+	if err := router.Serve(context.Background(), "ftp", addr); err == nil {
+		t.Error("expected error for unknown proto")
+	}
+}
+
+func TestShutdownHooksOwnDeadline(t *testing.T) {
+	router, _ := New(nil, nil)
+	router.SetLogger("empty")
+	router.SetShutdownTimeout(50 * time.Millisecond)
+	router.SetShutdownHookTimeout(time.Second)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	router.Get("/stuck", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	var hookErr error
+	var left time.Duration
+	router.OnShutdown("mongo", func(ctx context.Context) error {
+		hookErr = ctx.Err()
+		deadline, _ := ctx.Deadline()
+		left = time.Until(deadline)
+		return nil
+	})
+
+	addr := freeAddr(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- router.Serve(ctx, "http", addr)
+	}()
+	waitListening(t, addr)
+	go http.Get("http://" + addr + "/stuck")
+	<-started
+	cancel()
+
+	// the drain times out, the hook still gets its full deadline
+	if err := <-served; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the drain to time out, got %v", err)
+	}
+	if hookErr != nil || left < 500*time.Millisecond {
+		t.Errorf("hook should get its own deadline, got %v with %s left", hookErr, left)
+	}
+}
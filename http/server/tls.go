@@ -0,0 +1,263 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// ReloadInterval - default interval at which certificate files are checked
+// for changes
+const ReloadInterval = time.Minute
+
+// TLSConfig - tls settings used by RunTLS and ServeTLS
+type TLSConfig struct {
+	// CertFile, KeyFile - pem encoded certificate chain and private key,
+	// reloaded when either file changes, e.g. when rotated by cert-manager
+	CertFile string
+	KeyFile  string
+	// ReloadInterval - how often the files are checked, defaults to
+	// ReloadInterval, negative disables reloading
+	ReloadInterval time.Duration
+	// ClientCAFile - pem bundle of the authorities client certificates are
+	// verified against, setting it enables mTLS
+	ClientCAFile string
+	// ClientAuth - policy for client certificates, defaults to
+	// tls.RequireAndVerifyClientCert when ClientCAFile is set
+	ClientAuth tls.ClientAuthType
+	// MinVersion - lowest tls version accepted, defaults to tls.VersionTLS12
+	MinVersion uint16
+	// CipherSuites - suites allowed for tls 1.2 and below, nil keeps the Go
+	// defaults, tls 1.3 suites are not configurable
+	CipherSuites []uint16
+}
+
+// certReloader - serves the certificate and reloads it when the files change
+type certReloader struct {
+	certFile string
+	keyFile  string
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	modTime  time.Time
+}
+
+// newCertReloader - loads the certificate, failing when it is invalid
+func newCertReloader(certFile string, keyFile string) (*certReloader, error) {
+	cr := &certReloader{certFile: certFile, keyFile: keyFile}
+	if _, e := cr.reload(); e != nil {
+		return nil, e
+	}
+	return cr, nil
+}
+
+// GetCertificate - returns the current certificate, used as
+// tls.Config.GetCertificate
+func (cr *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	return cr.cert, nil
+}
+
+// lastModified - latest modification time of the certificate and key
+func (cr *certReloader) lastModified() (time.Time, error) {
+	crt, e := os.Stat(cr.certFile)
+	if e != nil {
+		return time.Time{}, e
+	}
+	key, e := os.Stat(cr.keyFile)
+	if e != nil {
+		return time.Time{}, e
+	}
+	if key.ModTime().After(crt.ModTime()) {
+		return key.ModTime(), nil
+	}
+	return crt.ModTime(), nil
+}
+
+// reload - loads the files if they changed since the last load, returns true
+// when the certificate was replaced, the current one is kept on error
+func (cr *certReloader) reload() (bool, error) {
+	mod, e := cr.lastModified()
+	if e != nil {
+		return false, e
+	}
+	cr.mu.RLock()
+	unchanged := cr.cert != nil && mod.Equal(cr.modTime)
+	cr.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+	cert, e := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if e != nil {
+		return false, e
+	}
+	cr.mu.Lock()
+	cr.cert = &cert
+	cr.modTime = mod
+	cr.mu.Unlock()
+	return true, nil
+}
+
+// watch - reloads the certificate every interval until stop is closed
+func (rtr Router) watch(cr *certReloader, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			changed, e := cr.reload()
+			if e != nil {
+				rtr.log.Error("SERVER_TLS", e)
+			} else if changed {
+				rtr.log.Out("SERVER_TLS", "Reloaded certificate "+cr.certFile)
+			}
+		}
+	}
+}
+
+// tlsConfig - builds the tls config, the reloader is nil when no certificate
+// files are set
+func (cfg TLSConfig) tlsConfig() (*tls.Config, *certReloader, error) {
+	tc := &tls.Config{
+		MinVersion:   cfg.MinVersion,
+		CipherSuites: cfg.CipherSuites,
+		ClientAuth:   cfg.ClientAuth,
+	}
+	if tc.MinVersion == 0 {
+		tc.MinVersion = tls.VersionTLS12
+	}
+	if cfg.ClientCAFile != "" {
+		pem, e := os.ReadFile(cfg.ClientCAFile)
+		if e != nil {
+			return nil, nil, e
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, nil, errors.New("No certificate found in " + cfg.ClientCAFile)
+		}
+		tc.ClientCAs = pool
+		if tc.ClientAuth == tls.NoClientCert {
+			tc.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	if cfg.CertFile == "" && cfg.KeyFile == "" {
+		return tc, nil, nil
+	}
+	cr, e := newCertReloader(cfg.CertFile, cfg.KeyFile)
+	if e != nil {
+		return nil, nil, e
+	}
+	tc.GetCertificate = cr.GetCertificate
+	return tc, cr, nil
+}
+
+// RunTLS - run and listen for https or h2 like RunS, with certificates
+// reloaded when their files change and optional client certificate
+// verification, see ServeTLS for a graceful shutdown
+func (rtr Router) RunTLS(proto string, hostport string, cfg TLSConfig) error {
+	rtr.log.Out("SERVER_RUNS", "Listening "+proto+" "+hostport)
+	e := rtr.runTLS(proto, hostport, cfg)
+	if e != nil {
+		rtr.log.Error("SERVER_RUNS", e)
+	}
+	return e
+}
+
+func (rtr Router) runTLS(proto string, hostport string, cfg TLSConfig) error {
+	srv, ln, cr, e := rtr.listenTLS(proto, hostport, cfg)
+	if e != nil {
+		return e
+	}
+	stop := rtr.reloadCerts(cr, cfg.ReloadInterval)
+	defer stop()
+	serving(srv)
+	return srv.ServeTLS(ln, "", "")
+}
+
+// ServeTLS - run and listen for https or h2 like RunTLS until ctx is
+// cancelled or the process receives SIGTERM or SIGINT, then shuts down
+// gracefully like Serve, returns nil on a clean shutdown
+func (rtr Router) ServeTLS(ctx context.Context, proto string, hostport string, cfg TLSConfig) error {
+	srv, ln, cr, e := rtr.listenTLS(proto, hostport, cfg)
+	if e != nil {
+		rtr.log.Error("SERVER_SERVE", e)
+		return e
+	}
+	rtr.log.Out("SERVER_SERVE", "Listening "+proto+" "+hostport)
+	stop := rtr.reloadCerts(cr, cfg.ReloadInterval)
+	defer stop()
+	return rtr.serve(ctx, srv, func() error {
+		return srv.ServeTLS(ln, "", "")
+	})
+}
+
+// listenTLS - builds the server for proto with the secure defaults and
+// listens on hostport
+func (rtr Router) listenTLS(proto string, hostport string, cfg TLSConfig) (*http.Server, net.Listener, *certReloader, error) {
+	tc, cr, e := cfg.tlsConfig()
+	if e != nil {
+		return nil, nil, nil, e
+	}
+	if cr == nil {
+		return nil, nil, nil, errors.New("Missing certificate")
+	}
+	rtr.limits.enable()
+	srvCfg := rtr.limits.get()
+	srv, e := rtr.tlsServer(proto, hostport, tc, srvCfg)
+	if e != nil {
+		return nil, nil, nil, e
+	}
+	ln, e := listen(hostport, srvCfg)
+	if e != nil {
+		return nil, nil, nil, e
+	}
+	return srv, ln, cr, nil
+}
+
+// reloadCerts - watches the certificate files every interval, ReloadInterval
+// when zero and never when negative, returns a function stopping it
+func (rtr Router) reloadCerts(cr *certReloader, interval time.Duration) func() {
+	if interval == 0 {
+		interval = ReloadInterval
+	}
+	if interval < 0 {
+		return func() {}
+	}
+	stop := make(chan struct{})
+	go rtr.watch(cr, interval, stop)
+	return func() { close(stop) }
+}
+
+// PeerCertificate - returns the verified client certificate of a mTLS
+// request or nil
+func PeerCertificate(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+// PeerIdentity - returns the identity of the verified client, the first URI
+// SAN (e.g. a SPIFFE id), else the first DNS SAN, else the common name, ""
+// when the client was not verified
+func PeerIdentity(r *http.Request) string {
+	crt := PeerCertificate(r)
+	if crt == nil {
+		return ""
+	}
+	if len(crt.URIs) > 0 {
+		return crt.URIs[0].String()
+	}
+	if len(crt.DNSNames) > 0 {
+		return crt.DNSNames[0]
+	}
+	return crt.Subject.CommonName
+}
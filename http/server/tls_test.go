@@ -0,0 +1,257 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert - certificate and key issued for tests
+type testCert struct {
+	crt  *x509.Certificate
+	key  *ecdsa.PrivateKey
+	cert tls.Certificate
+}
+
+// issue - creates a certificate signed by parent, self-signed when parent is
+// nil, client certificates get a spiffe uri
+func issue(t *testing.T, parent *testCert, serial int64, client bool) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if client {
+		id, _ := url.Parse("spiffe://example.org/orders")
+		tmpl.Subject.CommonName = "orders"
+		tmpl.IPAddresses = nil
+		tmpl.URIs = []*url.URL{id}
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+		tmpl.ExtKeyUsage = nil
+	} else {
+		signer, signerKey = parent.crt, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, _ := x509.ParseCertificate(der)
+	return &testCert{crt: crt, key: key, cert: tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}}
+}
+
+// write - writes the certificate and key as pem files in dir
+func (tc *testCert) write(t *testing.T, dir string) (string, string) {
+	t.Helper()
+	keyDer, err := x509.MarshalECPrivateKey(tc.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt := filepath.Join(dir, "tls.crt")
+	key := filepath.Join(dir, "tls.key")
+	if err := os.WriteFile(crt, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tc.crt.Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(key, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return crt, key
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	crtFile, keyFile := issue(t, nil, 1, false).write(t, dir)
+	cr, err := newCertReloader(crtFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial := func() int64 {
+		c, _ := cr.GetCertificate(nil)
+		crt, _ := x509.ParseCertificate(c.Certificate[0])
+		return crt.SerialNumber.Int64()
+	}
+	if changed, err := cr.reload(); changed || err != nil {
+		t.Errorf("unchanged files should not reload, got %v %v", changed, err)
+	}
+
+	// rotated files are picked up
+	issue(t, nil, 2, false).write(t, dir)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(crtFile, later, later)
+	if changed, err := cr.reload(); !changed || err != nil {
+		t.Fatalf("rotated files should reload, got %v %v", changed, err)
+	}
+	if serial() != 2 {
+		t.Errorf("expected the rotated certificate, got serial %d", serial())
+	}
+
+	// a broken rotation keeps serving the last good certificate
+	os.WriteFile(keyFile, []byte("garbage"), 0600)
+	later = later.Add(time.Minute)
+	os.Chtimes(keyFile, later, later)
+	if _, err := cr.reload(); err == nil {
+		t.Error("expected error for an invalid key")
+	}
+	if serial() != 2 {
+		t.Errorf("expected the last good certificate, got serial %d", serial())
+	}
+
+	if _, err := newCertReloader(filepath.Join(dir, "missing.crt"), keyFile); err == nil {
+		t.Error("expected error for missing files")
+	}
+}
+
+func TestRunTLSMutual(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, nil, 1, false)
+	srvCrt, srvKey := issue(t, ca, 2, false).write(t, dir)
+	caFile := filepath.Join(dir, "ca.crt")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.crt.Raw}), 0600)
+
+	router, _ := New(nil, nil)
+	router.SetLogger("empty")
+	router.Get("/whoami", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(PeerIdentity(r)))
+	})
+	addr := start(t, func(addr string) error {
+		return router.RunTLS("h2", addr, TLSConfig{
+			CertFile:     srvCrt,
+			KeyFile:      srvKey,
+			ClientCAFile: caFile,
+			MinVersion:   tls.VersionTLS13,
+		})
+	})
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.crt)
+	client := func(cfg *tls.Config) *http.Client {
+		cfg.RootCAs = roots
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: cfg, ForceAttemptHTTP2: true}}
+	}
+
+	// clients without a certificate are rejected
+	if res, err := client(&tls.Config{}).Get("https://" + addr + "/whoami"); err == nil {
+		res.Body.Close()
+		t.Error("expected a client without certificate to be rejected")
+	}
+	// versions below the minimum are rejected
+	if res, err := client(&tls.Config{MaxVersion: tls.VersionTLS12}).Get("https://" + addr + "/whoami"); err == nil {
+		res.Body.Close()
+		t.Error("expected tls 1.2 to be rejected")
+	}
+
+	peer := issue(t, ca, 3, true)
+	res, err := client(&tls.Config{Certificates: []tls.Certificate{peer.cert}}).Get("https://" + addr + "/whoami")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if string(b) != "spiffe://example.org/orders" {
+		t.Errorf("expected the peer identity, got %q", b)
+	}
+	if res.ProtoMajor != 2 {
+		t.Errorf("expected http/2, got %s", res.Proto)
+	}
+}
+
+func TestServeTLSGracefulShutdown(t *testing.T) {
+	dir := t.TempDir()
+	crt, key := issue(t, nil, 1, false).write(t, dir)
+	router, _ := New(nil, nil)
+	router.SetLogger("empty")
+	started := make(chan struct{})
+	router.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("done"))
+	})
+	hooked := make(chan struct{})
+	router.OnShutdown("mongo", func(ctx context.Context) error {
+		close(hooked)
+		return nil
+	})
+
+	addr := freeAddr(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := make(chan error, 1)
+	go func() {
+		served <- router.ServeTLS(ctx, "h2", addr, TLSConfig{CertFile: crt, KeyFile: key})
+	}()
+	waitListening(t, addr)
+
+	body := make(chan string, 1)
+	go func() {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, ForceAttemptHTTP2: true}}
+		res, err := client.Get("https://" + addr + "/slow")
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		b, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		body <- string(b)
+	}()
+	<-started
+	cancel()
+	if b := <-body; b != "done" {
+		t.Errorf("in-flight request should complete, got %q", b)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("expected a clean shutdown, got %v", err)
+	}
+	select {
+	case <-hooked:
+	default:
+		t.Error("shutdown hooks should run")
+	}
+	if router.Ready() {
+		t.Error("router should not be ready after shutdown")
+	}
+	if err := router.ServeTLS(context.Background(), "h2", addr, TLSConfig{}); err == nil {
+		t.Error("expected error without certificate")
+	}
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.pem")
+	os.WriteFile(bad, []byte("not a certificate"), 0600)
+	if _, _, err := (TLSConfig{ClientCAFile: bad}).tlsConfig(); err == nil {
+		t.Error("expected error for an invalid ca bundle")
+	}
+	router, _ := New(nil, nil)
+	router.SetLogger("empty")
+	if err := router.RunTLS("h2", freeAddr(t), TLSConfig{}); err == nil {
+		t.Error("expected error without certificate")
+	}
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/kelchy/go-lib/log"
+)
+
+const (
+	// RequestIDHeader - header carrying the request id
+	RequestIDHeader = "X-Request-ID"
+	// TraceparentHeader - w3c trace context header identifying the trace
+	TraceparentHeader = "traceparent"
+	// TracestateHeader - w3c trace context header with vendor data
+	TracestateHeader = "tracestate"
+)
+
+type ctxKey int
+
+const (
+	// traceKey - the full trace context, ids are also stored with the log
+	// package so loggers from log.Ctx correlate lines
+	traceKey ctxKey = iota
+	// logKey - the logger of the router serving the request
+	logKey
+)
+
+// TraceContext - w3c trace context of a request
+type TraceContext struct {
+	// TraceID - 32 hex characters shared by every span of the trace
+	TraceID string
+	// SpanID - 16 hex characters identifying the span of this request
+	SpanID string
+	// ParentID - span id received from the caller, "" when the trace started here
+	ParentID string
+	// Flags - trace flags, 01 when sampled
+	Flags string
+	// State - tracestate received from the caller, passed on unchanged
+	State string
+}
+
+// Traceparent - formats the traceparent header for calls made within the span
+func (tc TraceContext) Traceparent() string {
+	return "00-" + tc.TraceID + "-" + tc.SpanID + "-" + tc.Flags
+}
+
+// RequestID - returns the id of the request handled with ctx or "", same as
+// log.RequestIDFromContext
+func RequestID(ctx context.Context) string {
+	return log.RequestIDFromContext(ctx)
+}
+
+// Trace - returns the trace context of the request handled with ctx
+func Trace(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceKey).(TraceContext)
+	return tc, ok
+}
+
+// requestContext - accepts or generates the request id and trace context,
+// stores them in the request context and echoes them in the response,
+// handlers logging with log.Ctx(r.Context()) get request_id, trace_id and
+// span_id on every line
+func (rtr *Router) requestContext(w http.ResponseWriter, r *http.Request) *http.Request {
+	id := r.Header.Get(RequestIDHeader)
+	if !validRequestID(id) {
+		id = randomHex(16)
+	}
+	tc, ok := parseTraceparent(r.Header.Get(TraceparentHeader))
+	if ok {
+		tc.ParentID = tc.SpanID
+		tc.State = r.Header.Get(TracestateHeader)
+	} else {
+		// no valid parent, a new trace starts here and tracestate is dropped
+		tc = TraceContext{TraceID: randomHex(16), Flags: "01"}
+	}
+	tc.SpanID = randomHex(8)
+
+	w.Header().Set(RequestIDHeader, id)
+	w.Header().Set(TraceparentHeader, tc.Traceparent())
+	if tc.State != "" {
+		w.Header().Set(TracestateHeader, tc.State)
+	}
+	ctx := log.ContextWithRequestID(r.Context(), id)
+	ctx = log.ContextWithTrace(ctx, tc.TraceID, tc.SpanID)
+	ctx = context.WithValue(ctx, traceKey, tc)
+	ctx = context.WithValue(ctx, logKey, rtr.log)
+	return r.WithContext(ctx)
+}
+
+// validRequestID - accepts ids of up to 128 printable characters so callers
+// cannot inject into logs or headers
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseTraceparent - parses a traceparent header, versions other than 00
+// are parsed as 00 as the specification requires
+func parseTraceparent(h string) (TraceContext, bool) {
+	h = strings.TrimSpace(h)
+	if len(h) < 55 || (len(h) > 55 && h[55] != '-') {
+		return TraceContext{}, false
+	}
+	version := h[0:2]
+	if !isHex(version) || version == "ff" || (version == "00" && len(h) != 55) {
+		return TraceContext{}, false
+	}
+	if h[2] != '-' || h[35] != '-' || h[52] != '-' {
+		return TraceContext{}, false
+	}
+	tc := TraceContext{TraceID: h[3:35], SpanID: h[36:52], Flags: h[53:55]}
+	if !isHex(tc.TraceID) || !isHex(tc.SpanID) || !isHex(tc.Flags) ||
+		tc.TraceID == strings.Repeat("0", 32) || tc.SpanID == strings.Repeat("0", 16) {
+		return TraceContext{}, false
+	}
+	return tc, true
+}
+
+// isHex - true for lowercase hex strings
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// randomHex - returns n random bytes hex encoded
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
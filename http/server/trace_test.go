@@ -0,0 +1,105 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kelchy/go-lib/log"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		h  string
+		ok bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		// future versions may append fields
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if _, ok := parseTraceparent(tt.h); ok != tt.ok {
+			t.Errorf("%q: expected %v, got %v", tt.h, tt.ok, ok)
+		}
+	}
+}
+
+func TestRequestContext(t *testing.T) {
+	router, _ := New(nil, nil)
+	var id string
+	var tc TraceContext
+	router.Get("/ids", func(w http.ResponseWriter, r *http.Request) {
+		id = RequestID(r.Context())
+		tc, _ = Trace(r.Context())
+		// loggers from the log package see the same ids
+		if log.RequestIDFromContext(r.Context()) != id {
+			t.Error("request id should be stored for the log package")
+		}
+		if traceID, spanID := log.TraceFromContext(r.Context()); traceID != tc.TraceID || spanID != tc.SpanID {
+			t.Errorf("trace should be stored for the log package, got %s %s", traceID, spanID)
+		}
+	})
+
+	// ids from the caller are kept and the request becomes a child span
+	req := httptest.NewRequest("GET", "/ids", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	req.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set(TracestateHeader, "vendor=value")
+
+	var out bytes.Buffer
+	l, _ := log.NewWithOptions(log.WithOutput(&out, &out))
+	router.SetLog(l)
+	res := httptest.NewRecorder()
+	router.Engine.ServeHTTP(res, req)
+
+	if id != "abc-123" || res.Header().Get(RequestIDHeader) != "abc-123" {
+		t.Errorf("expected the request id to be kept, got %q", id)
+	}
+	if tc.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || tc.ParentID != "00f067aa0ba902b7" ||
+		tc.SpanID == tc.ParentID || len(tc.SpanID) != 16 || tc.Flags != "01" || tc.State != "vendor=value" {
+		t.Errorf("unexpected trace context %+v", tc)
+	}
+	if res.Header().Get(TraceparentHeader) != tc.Traceparent() || res.Header().Get(TracestateHeader) != "vendor=value" {
+		t.Errorf("trace context should be echoed, got %v", res.Header())
+	}
+	var access map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &access); err != nil {
+		t.Fatalf("expected one access log line, got %s", out.String())
+	}
+	if access["scope"] != "/ids" || access["method"] != "GET" || access["status"] != float64(200) || access["src"] != "192.0.2.1:1234" {
+		t.Errorf("unexpected access log %s", out.String())
+	}
+	if _, ok := access["ms"].(float64); !ok {
+		t.Errorf("latency should be a number, got %s", out.String())
+	}
+	if access["request_id"] != "abc-123" || access["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("access log should carry the ids, got %s", out.String())
+	}
+
+	// invalid or missing ids are generated
+	router.SetLogger("empty")
+	req = httptest.NewRequest("GET", "/ids", nil)
+	req.Header.Set(RequestIDHeader, "bad\nid")
+	req.Header.Set(TraceparentHeader, "garbage")
+	req.Header.Set(TracestateHeader, "vendor=value")
+	res = httptest.NewRecorder()
+	router.Engine.ServeHTTP(res, req)
+	if len(id) != 32 || id == "bad\nid" {
+		t.Errorf("expected a generated request id, got %q", id)
+	}
+	if len(tc.TraceID) != 32 || tc.ParentID != "" || tc.State != "" {
+		t.Errorf("expected a new trace, got %+v", tc)
+	}
+	if _, ok := parseTraceparent(res.Header().Get(TraceparentHeader)); !ok {
+		t.Errorf("invalid traceparent in response %q", res.Header().Get(TraceparentHeader))
+	}
+}
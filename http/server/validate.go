@@ -0,0 +1,159 @@
+package server
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// FieldError - a field that failed validation
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Msg   string `json:"msg"`
+}
+
+// ValidationError - fields of a request that failed validation
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// Error - lists the failed fields
+func (ve *ValidationError) Error() string {
+	msgs := make([]string, len(ve.Fields))
+	for i, f := range ve.Fields {
+		msgs[i] = f.Field + " " + f.Msg
+	}
+	return "Invalid request: " + strings.Join(msgs, ", ")
+}
+
+// Validate - checks the validate tags of a struct, rules are separated by
+// commas:
+//
+//	required     - not the zero value
+//	min=n, max=n - bounds of numbers, or of the length of strings, slices and maps
+//	oneof=a b c  - one of the space separated values
+//
+// nested structs are validated too, returns a *ValidationError or nil
+func Validate(v interface{}) error {
+	var ve ValidationError
+	validateStruct(reflect.ValueOf(v), "", &ve)
+	if len(ve.Fields) > 0 {
+		return &ve
+	}
+	return nil
+}
+
+func validateStruct(rv reflect.Value, prefix string, ve *ValidationError) {
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return
+	}
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		fv := rv.Field(i)
+		name := prefix + fieldName(sf)
+		if tag := sf.Tag.Get("validate"); tag != "" && tag != "-" {
+			for _, rule := range strings.Split(tag, ",") {
+				if msg := checkRule(fv, rule); msg != "" {
+					ve.Fields = append(ve.Fields, FieldError{Field: name, Rule: rule, Msg: msg})
+				}
+			}
+		}
+		if fv.Kind() == reflect.Struct || (fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct) {
+			validateStruct(fv, name+".", ve)
+		}
+	}
+}
+
+// fieldName - name of the field as the client sent it
+func fieldName(sf reflect.StructField) string {
+	for _, key := range []string{"json", "query", "param"} {
+		if n := strings.Split(sf.Tag.Get(key), ",")[0]; n != "" && n != "-" {
+			return n
+		}
+	}
+	return sf.Name
+}
+
+// checkRule - returns why fv breaks rule or ""
+func checkRule(fv reflect.Value, rule string) string {
+	name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+	for fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			if name == "required" {
+				return "is required"
+			}
+			// optional fields are only checked when set
+			return ""
+		}
+		fv = fv.Elem()
+	}
+	switch name {
+	case "required":
+		if fv.IsZero() {
+			return "is required"
+		}
+	case "min", "max":
+		bound, e := strconv.ParseFloat(arg, 64)
+		if e != nil {
+			return "has an invalid rule"
+		}
+		n, isLen := measure(fv)
+		if (name == "min" && n < bound) || (name == "max" && n > bound) {
+			if isLen {
+				return "length must be at " + map[string]string{"min": "least ", "max": "most "}[name] + arg
+			}
+			return "must be at " + map[string]string{"min": "least ", "max": "most "}[name] + arg
+		}
+	case "oneof":
+		s := valueString(fv)
+		for _, opt := range strings.Fields(arg) {
+			if s == opt {
+				return ""
+			}
+		}
+		return "must be one of " + arg
+	default:
+		return "has an unknown rule"
+	}
+	return ""
+}
+
+// measure - value of numbers, length of everything else
+func measure(fv reflect.Value) (float64, bool) {
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(fv.Int()), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(fv.Uint()), false
+	case reflect.Float32, reflect.Float64:
+		return fv.Float(), false
+	case reflect.String:
+		return float64(len([]rune(fv.String()))), true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(fv.Len()), true
+	}
+	return 0, true
+}
+
+func valueString(fv reflect.Value) string {
+	switch fv.Kind() {
+	case reflect.String:
+		return fv.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(fv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(fv.Uint(), 10)
+	}
+	return ""
+}
@@ -0,0 +1,205 @@
+package log
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Overflow - what an async logger does when its buffer is full
+type Overflow int
+
+const (
+	// OverflowDrop - drops the line and reports the count later, the caller
+	// never waits, this is the default
+	OverflowDrop Overflow = iota
+	// OverflowBlock - waits until the writer goroutine makes room
+	OverflowBlock
+)
+
+// Async - settings of the asynchronous mode
+type Async struct {
+	// Size - number of lines the buffer holds, defaults to 1024
+	Size int
+	// Overflow - policy when the buffer is full
+	Overflow Overflow
+}
+
+// record - a line waiting to be encoded and written, the stack is captured
+// by the caller so it still points at the failure site
+type record struct {
+	ts      time.Time
+	lvl     Level
+	scope   string
+	msg     string
+	stack   string
+	fields  []Field
+	json    bool
+	console bool
+	// flush - closed by the writer once every record queued before it is written
+	flush chan struct{}
+	stop  bool
+}
+
+type async struct {
+	// dropped - first so it is 64-bit aligned for atomic use on 32-bit platforms
+	dropped int64
+	core    *core
+	block   bool
+	json    bool
+	console bool
+	queue   chan record
+	mu      sync.RWMutex
+	closed  bool
+	done    chan struct{}
+}
+
+// SetAsync - moves encoding and writing of lines to a goroutine fed by a
+// bounded buffer, use Flush to wait for queued lines and Close on shutdown to
+// write everything left, the change is visible to every copy of the logger
+// values inside Any fields are encoded later and must not be modified
+func (l *Log) SetAsync(cfg Async) {
+	if l.core == nil {
+		l.core = newCore()
+	}
+	if cfg.Size <= 0 {
+		cfg.Size = 1024
+	}
+	a := &async{
+		core:    l.core,
+		block:   cfg.Overflow == OverflowBlock,
+		json:    l.json,
+		console: l.console,
+		queue:   make(chan record, cfg.Size),
+		done:    make(chan struct{}),
+	}
+	go a.run()
+
+	l.core.mu.Lock()
+	old := l.core.async
+	l.core.async = a
+	l.core.closers = append(l.core.closers, a)
+	l.core.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+}
+
+// Flush - waits until every line logged before the call is written, it
+// returns immediately when the logger is not asynchronous
+func (l Log) Flush() {
+	if l.core == nil {
+		return
+	}
+	l.core.mu.RLock()
+	a := l.core.async
+	l.core.mu.RUnlock()
+	if a != nil {
+		a.flush()
+	}
+}
+
+// dispatch - queues the record or writes it synchronously
+func (c *core) dispatch(r record) {
+	if c != nil {
+		c.mu.RLock()
+		a := c.async
+		c.mu.RUnlock()
+		if a != nil && a.enqueue(r) {
+			return
+		}
+	}
+	c.write(r)
+}
+
+// enqueue - returns false once closed so the caller writes synchronously
+func (a *async) enqueue(r record) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return false
+	}
+	if a.block {
+		a.queue <- r
+		return true
+	}
+	select {
+	case a.queue <- r:
+	default:
+		atomic.AddInt64(&a.dropped, 1)
+	}
+	return true
+}
+
+func (a *async) flush() {
+	a.mu.RLock()
+	if a.closed {
+		a.mu.RUnlock()
+		return
+	}
+	ch := make(chan struct{})
+	// markers always wait for room, whatever the overflow policy
+	a.queue <- record{flush: ch}
+	a.mu.RUnlock()
+	<-ch
+}
+
+func (a *async) run() {
+	defer close(a.done)
+	for r := range a.queue {
+		switch {
+		case r.flush != nil:
+			a.report()
+			close(r.flush)
+		case r.stop:
+			a.report()
+			return
+		default:
+			a.core.write(r)
+			if len(a.queue) == 0 {
+				a.report()
+			}
+		}
+	}
+}
+
+// report - writes how many lines were dropped since the last report
+func (a *async) report() {
+	n := atomic.SwapInt64(&a.dropped, 0)
+	if n == 0 {
+		return
+	}
+	r := record{
+		ts:      a.core.clock(),
+		lvl:     LevelWarn,
+		scope:   "LOG_ASYNC",
+		msg:     "dropped " + strconv.FormatInt(n, 10) + " log lines, buffer full",
+		fields:  []Field{Int64("dropped", n)},
+		json:    a.json,
+		console: a.console,
+	}
+	a.core.write(r)
+}
+
+// Close - writes every queued line and stops the writer goroutine, lines
+// logged afterwards are written synchronously
+func (a *async) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		<-a.done
+		return nil
+	}
+	a.closed = true
+	a.queue <- record{stop: true}
+	a.mu.Unlock()
+	<-a.done
+
+	a.core.mu.Lock()
+	if a.core.async == a {
+		a.core.async = nil
+	}
+	a.core.mu.Unlock()
+	return nil
+}
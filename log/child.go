@@ -0,0 +1,82 @@
+package log
+
+// With - returns a copy of the logger adding fields to every line, the
+// original logger is not modified
+func (l Log) With(fields ...Field) Log {
+	return l.bind(fields)
+}
+
+// Named - returns a copy of the logger whose scopes are prefixed with name,
+// nesting builds a dotted hierarchy, e.g. l.Named("orders").Named("mongo")
+// writes the scope MONGO_FIND as orders.mongo.MONGO_FIND
+func (l Log) Named(name string) Log {
+	if name == "" {
+		return l
+	}
+	if l.name != "" {
+		name = l.name + "." + name
+	}
+	l.name = name
+	return l
+}
+
+// scoped - prefixes scope with the name of the logger
+func (l Log) scoped(scope string) string {
+	if l.name == "" {
+		return scope
+	}
+	if scope == "" {
+		return l.name
+	}
+	return l.name + "." + scope
+}
+
+// bound - fields added to every line of a derived logger, never modified
+// after creation so copies can share it
+type bound struct {
+	fields []Field
+}
+
+// bind - returns a copy of the logger with fields added to the bound ones,
+// a field replaces a bound field with the same key
+func (l Log) bind(fields []Field) Log {
+	if len(fields) == 0 {
+		return l
+	}
+	var base []Field
+	if l.bound != nil {
+		base = l.bound.fields
+	}
+	l.bound = &bound{fields: override(base, fields)}
+	return l
+}
+
+// merge - returns the bound fields followed by the fields of the call, a
+// field of the call replaces a bound field with the same key
+func (l Log) merge(fields []Field) []Field {
+	if l.bound == nil {
+		return fields
+	}
+	if len(fields) == 0 {
+		return l.bound.fields
+	}
+	return override(l.bound.fields, fields)
+}
+
+// override - returns a new slice with base followed by fields, a field takes
+// the place of an earlier one with the same key so no key is written twice
+func override(base []Field, fields []Field) []Field {
+	all := make([]Field, len(base), len(base)+len(fields))
+	copy(all, base)
+next:
+	for _, f := range fields {
+		for i := range all {
+			if all[i].Key == f.Key {
+				all[i] = f
+				continue next
+			}
+		}
+		all = append(all, f)
+	}
+	return all
+}
@@ -0,0 +1,126 @@
+package log
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scopeWidth - scopes shorter than this are padded so messages line up
+const scopeWidth = 20
+
+const (
+	colorReset = "\x1b[0m"
+	colorDim   = "\x1b[2m"
+	colorBold  = "\x1b[1m"
+)
+
+var levelColors = map[Level]string{
+	LevelTrace: "\x1b[90m",
+	LevelDebug: "\x1b[34m",
+	LevelInfo:  "\x1b[32m",
+	LevelWarn:  "\x1b[33m",
+	LevelError: "\x1b[31m",
+	LevelFatal: "\x1b[1;31m",
+}
+
+// ConsoleEnable - used to switch to human readable lines for local
+// development, levels are colored when writing to a terminal, also enabled by
+// setting LOG_FORMAT=console, JSONEnable and JSONDisable switch back
+func (l *Log) ConsoleEnable() {
+	l.json = false
+	l.console = true
+}
+
+// formatOf - applies LOG_FORMAT to a new logger
+func (l *Log) formatOf(format string) {
+	switch strings.ToLower(format) {
+	case "console":
+		l.ConsoleEnable()
+	case "text":
+		l.JSONDisable()
+	case "json":
+		l.JSONEnable()
+	}
+}
+
+// isTerminal - true if w is a character device and NO_COLOR is not set
+func isTerminal(w io.Writer) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, e := f.Stat()
+	return e == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// encodeConsole - renders the record as aligned columns: time, level, scope,
+// message and fields, the stack follows indented on its own lines
+func (r record) encodeConsole(color bool) []byte {
+	var b strings.Builder
+	paint := func(c string, s string) {
+		if color && c != "" {
+			b.WriteString(c + s + colorReset)
+		} else {
+			b.WriteString(s)
+		}
+	}
+	paint(colorDim, r.ts.Format("2006-01-02T15:04:05.000Z07:00"))
+	b.WriteByte(' ')
+	lvl := strings.ToUpper(r.lvl.String())
+	paint(levelColors[r.lvl], lvl+strings.Repeat(" ", 5-len(lvl)))
+	b.WriteByte(' ')
+	scope := r.scope
+	if len(scope) < scopeWidth {
+		scope += strings.Repeat(" ", scopeWidth-len(scope))
+	}
+	paint(colorBold, scope)
+	b.WriteByte(' ')
+	b.WriteString(r.msg)
+	for _, f := range r.fields {
+		b.WriteString("  ")
+		paint(colorDim, f.Key+"=")
+		b.WriteString(consoleValue(f))
+	}
+	b.WriteByte('\n')
+	if r.stack != "" {
+		for _, line := range strings.Split(strings.TrimRight(r.stack, "\n"), "\n") {
+			b.WriteString("    ")
+			paint(colorDim, line)
+			b.WriteByte('\n')
+		}
+	}
+	return []byte(b.String())
+}
+
+// consoleValue - strings are quoted only when needed, objects are rendered
+// as {key=value ...} so nested fields read the same as top level ones
+func consoleValue(f Field) string {
+	switch f.kind {
+	case kindString, kindError:
+		s, _ := f.Value().(string)
+		if f.kind == kindError && f.iface == nil {
+			return "<nil>"
+		}
+		if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+			return strconv.Quote(s)
+		}
+		return s
+	case kindDuration:
+		return time.Duration(f.num).String()
+	case kindTime:
+		return f.iface.(time.Time).Format(time.RFC3339Nano)
+	case kindObject:
+		parts := make([]string, 0, len(f.obj))
+		for _, o := range f.obj {
+			parts = append(parts, o.Key+"="+consoleValue(o))
+		}
+		return "{" + strings.Join(parts, " ") + "}"
+	}
+	return fieldsText([]Field{f})[len(f.Key)+1:]
+}
@@ -0,0 +1,46 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConsole(t *testing.T) {
+	t.Setenv("LOG_FORMAT", "console")
+	l, _ := New("")
+	var buf bytes.Buffer
+	l.SetOutput(&buf, &buf)
+	l.SetStack(StackConfig{Depth: 1})
+
+	l.OutFields("HTTP", "done", String("path", "/a b"), Duration("took", 1500*time.Microsecond),
+		Object("user", String("id", "u1")), Err(nil))
+	line := buf.String()
+	if !strings.Contains(line, " INFO  HTTP                 done  path=\"/a b\"  took=1.5ms  user={id=u1}  error=<nil>\n") {
+		t.Errorf("unexpected console line %q", line)
+	}
+	if strings.Contains(line, "\x1b[") {
+		t.Error("no colors expected when not writing to a terminal")
+	}
+
+	buf.Reset()
+	l.Error("DB", errors.New("failed"))
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], " ERROR DB ") || !strings.HasPrefix(lines[1], "    github.com/kelchy/go-lib/log.TestConsole") {
+		t.Errorf("unexpected console error %q", buf.String())
+	}
+
+	r := record{ts: time.Now(), lvl: LevelWarn, scope: "S", msg: "m", console: true}
+	if colored := string(r.encode(true)); !strings.Contains(colored, "\x1b[33mWARN \x1b[0m") {
+		t.Errorf("expected colored level, got %q", colored)
+	}
+
+	l.JSONEnable()
+	buf.Reset()
+	l.Out("HTTP", "json again")
+	if !strings.HasPrefix(buf.String(), `{"ts":`) {
+		t.Errorf("JSONEnable should switch back to json, got %q", buf.String())
+	}
+}
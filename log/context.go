@@ -0,0 +1,72 @@
+package log
+
+import (
+	"context"
+)
+
+type ctxKey int
+
+const (
+	requestIDKey ctxKey = iota
+	traceKey
+)
+
+// trace - ids of the w3c trace context stored by ContextWithTrace
+type trace struct {
+	traceID string
+	spanID  string
+}
+
+// std - logger used by WithContext
+var std, _ = New("")
+
+// ContextWithRequestID - returns a copy of ctx carrying the request id, used
+// by middleware so every log line of the request can be correlated
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestIDFromContext - returns the request id stored in ctx or ""
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// ContextWithTrace - returns a copy of ctx carrying the trace and span id
+func ContextWithTrace(ctx context.Context, traceID string, spanID string) context.Context {
+	return context.WithValue(ctx, traceKey, trace{traceID: traceID, spanID: spanID})
+}
+
+// TraceFromContext - returns the trace and span id stored in ctx or ""
+func TraceFromContext(ctx context.Context) (string, string) {
+	if ctx == nil {
+		return "", ""
+	}
+	t, _ := ctx.Value(traceKey).(trace)
+	return t.traceID, t.spanID
+}
+
+// Ctx - returns a copy of the logger adding request_id, trace_id and span_id
+// found in ctx to every line, ids missing from ctx are left out
+func (l Log) Ctx(ctx context.Context) Log {
+	var fields []Field
+	if id := RequestIDFromContext(ctx); id != "" {
+		fields = append(fields, String("request_id", id))
+	}
+	traceID, spanID := TraceFromContext(ctx)
+	if traceID != "" {
+		fields = append(fields, String("trace_id", traceID))
+	}
+	if spanID != "" {
+		fields = append(fields, String("span_id", spanID))
+	}
+	return l.bind(fields)
+}
+
+// WithContext - same as Ctx on a standard logger
+func WithContext(ctx context.Context) Log {
+	return std.Ctx(ctx)
+}
@@ -0,0 +1,214 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type fieldKind uint8
+
+const (
+	kindString fieldKind = iota
+	kindInt
+	kindFloat
+	kindBool
+	kindDuration
+	kindTime
+	kindError
+	kindObject
+	kindAny
+)
+
+// Field - typed key/value emitted as a top level key of a json line
+type Field struct {
+	Key   string
+	kind  fieldKind
+	str   string
+	num   int64
+	float float64
+	obj   []Field
+	iface interface{}
+}
+
+// String - string field
+func String(key string, val string) Field {
+	return Field{Key: key, kind: kindString, str: val}
+}
+
+// Int - int field
+func Int(key string, val int) Field {
+	return Field{Key: key, kind: kindInt, num: int64(val)}
+}
+
+// Int64 - int64 field
+func Int64(key string, val int64) Field {
+	return Field{Key: key, kind: kindInt, num: val}
+}
+
+// Float - float64 field, NaN and infinities are written as strings
+func Float(key string, val float64) Field {
+	return Field{Key: key, kind: kindFloat, float: val}
+}
+
+// Bool - bool field
+func Bool(key string, val bool) Field {
+	var n int64
+	if val {
+		n = 1
+	}
+	return Field{Key: key, kind: kindBool, num: n}
+}
+
+// Duration - duration field, written as fractional milliseconds so it can be
+// indexed as a number
+func Duration(key string, val time.Duration) Field {
+	return Field{Key: key, kind: kindDuration, num: int64(val)}
+}
+
+// Time - timestamp field, written in RFC3339 with nanoseconds
+func Time(key string, val time.Time) Field {
+	return Field{Key: key, kind: kindTime, iface: val}
+}
+
+// Err - error field with the key "error", a nil error is written as null
+func Err(err error) Field {
+	return NamedErr("error", err)
+}
+
+// NamedErr - error field with a custom key, a nil error is written as null
+func NamedErr(key string, err error) Field {
+	return Field{Key: key, kind: kindError, iface: err}
+}
+
+// Object - nested json object made of other fields
+func Object(key string, fields ...Field) Field {
+	return Field{Key: key, kind: kindObject, obj: fields}
+}
+
+// Any - field holding any value, written using encoding/json
+func Any(key string, val interface{}) Field {
+	return Field{Key: key, kind: kindAny, iface: val}
+}
+
+// Value - returns the value of the field as a plain go value, objects are
+// returned as map[string]interface{}
+func (f Field) Value() interface{} {
+	switch f.kind {
+	case kindString:
+		return f.str
+	case kindInt:
+		return f.num
+	case kindFloat:
+		return f.float
+	case kindBool:
+		return f.num == 1
+	case kindDuration:
+		return time.Duration(f.num)
+	case kindError:
+		if f.iface == nil {
+			return nil
+		}
+		return f.iface.(error).Error()
+	case kindObject:
+		m := make(map[string]interface{}, len(f.obj))
+		for _, o := range f.obj {
+			m[o.Key] = o.Value()
+		}
+		return m
+	}
+	return f.iface
+}
+
+// reserved - keys of Line that fields cannot overwrite
+var reserved = map[string]bool{"ts": true, "level": true, "scope": true, "msg": true, "stack": true}
+
+// appendFields - writes fields as json members, top level fields follow the
+// fixed keys of the line so every member is preceded by a comma
+func appendFields(buf *bytes.Buffer, fields []Field, top bool) {
+	for i, f := range fields {
+		key := f.Key
+		if top && reserved[key] {
+			key = "fields." + key
+		}
+		if top || i > 0 {
+			buf.WriteByte(',')
+		}
+		appendString(buf, key)
+		buf.WriteByte(':')
+		f.appendJSON(buf)
+	}
+}
+
+func (f Field) appendJSON(buf *bytes.Buffer) {
+	switch f.kind {
+	case kindString:
+		appendString(buf, f.str)
+	case kindInt:
+		buf.WriteString(strconv.FormatInt(f.num, 10))
+	case kindFloat:
+		appendFloat(buf, f.float)
+	case kindBool:
+		buf.WriteString(strconv.FormatBool(f.num == 1))
+	case kindDuration:
+		appendFloat(buf, float64(f.num)/float64(time.Millisecond))
+	case kindError:
+		if f.iface == nil {
+			buf.WriteString("null")
+		} else {
+			appendString(buf, f.iface.(error).Error())
+		}
+	case kindObject:
+		buf.WriteByte('{')
+		appendFields(buf, f.obj, false)
+		buf.WriteByte('}')
+	default:
+		j, e := json.Marshal(f.iface)
+		if e != nil {
+			appendString(buf, fmt.Sprint(f.iface))
+		} else {
+			buf.Write(j)
+		}
+	}
+}
+
+func appendString(buf *bytes.Buffer, s string) {
+	// json.Marshal on a string cannot fail
+	j, _ := json.Marshal(s)
+	buf.Write(j)
+}
+
+func appendFloat(buf *bytes.Buffer, v float64) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		appendString(buf, strconv.FormatFloat(v, 'f', -1, 64))
+		return
+	}
+	buf.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+}
+
+// fieldsText - renders fields as key=value pairs for non json output
+func fieldsText(fields []Field) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(fields))
+	for _, f := range fields {
+		var v string
+		switch f.kind {
+		case kindString, kindError:
+			v = fmt.Sprint(f.Value())
+		case kindDuration:
+			v = time.Duration(f.num).String()
+		default:
+			var buf bytes.Buffer
+			f.appendJSON(&buf)
+			v = buf.String()
+		}
+		parts = append(parts, f.Key+"="+v)
+	}
+	return strings.Join(parts, " ")
+}
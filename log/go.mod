@@ -1,4 +1,4 @@
-//v0.0.11
+//v0.0.35
 module github.com/kelchy/go-lib/log
 
 go 1.18
@@ -0,0 +1,279 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Hook - receives lines before they are written, e.g. to forward errors to
+// an alerting backend
+type Hook interface {
+	Fire(line Line) error
+}
+
+// HookFunc - adapter to use a function as a Hook
+type HookFunc func(line Line) error
+
+// Fire - calls fn(line)
+func (fn HookFunc) Fire(line Line) error {
+	return fn(line)
+}
+
+// HookOptions - which lines a hook receives and how
+type HookOptions struct {
+	// MinLevel - lines below this level are skipped, the zero value is info
+	MinLevel Level
+	// Scopes - only lines whose scope starts with one of these are passed,
+	// empty passes all scopes
+	Scopes []string
+	// Async - fire from a goroutine so slow hooks never delay the caller,
+	// lines are dropped when Buffer lines are already waiting
+	Async bool
+	// Buffer - size of the async queue, defaults to 256
+	Buffer int
+}
+
+type hook struct {
+	// dropped - first so it is 64-bit aligned for atomic use on 32-bit platforms
+	dropped int64
+	h       Hook
+	opts    HookOptions
+	queue   chan Line
+	mu      sync.RWMutex
+	closed  bool
+	done    chan struct{}
+	report  func(e error)
+}
+
+// AddHook - registers a hook fired with the fully formed line, after
+// redaction and with its stack, before it is written, errors returned by the
+// hook are written to the error output with scope LOG_HOOK, the change is
+// visible to every copy of the logger, use Close to drain async hooks
+func (l *Log) AddHook(h Hook, opts HookOptions) {
+	if l.core == nil {
+		l.core = newCore()
+	}
+	c := l.core
+	hk := &hook{h: h, opts: opts}
+	hk.report = func(e error) {
+		c.write(record{ts: c.clock(), lvl: LevelError, scope: "LOG_HOOK", msg: e.Error(), json: l.json, console: l.console})
+	}
+	if opts.Async {
+		if opts.Buffer <= 0 {
+			opts.Buffer = 256
+		}
+		hk.queue = make(chan Line, opts.Buffer)
+		hk.done = make(chan struct{})
+		go hk.run()
+	}
+	c.mu.Lock()
+	// copy on write so lines being fired keep a consistent list
+	hooks := make([]*hook, len(c.hooks), len(c.hooks)+1)
+	copy(hooks, c.hooks)
+	c.hooks = append(hooks, hk)
+	if opts.Async {
+		c.closers = append(c.closers, hk)
+	}
+	c.mu.Unlock()
+}
+
+// fire - passes the record to every matching hook
+func (c *core) fire(r record) {
+	if c == nil {
+		return
+	}
+	c.mu.RLock()
+	hooks := c.hooks
+	c.mu.RUnlock()
+	if len(hooks) == 0 {
+		return
+	}
+	line := Line{Ts: r.ts, Level: r.lvl.String(), Scope: r.scope, Msg: r.msg, Stack: r.stack, Fields: r.fields}
+	for _, hk := range hooks {
+		if hk.match(r.lvl, r.scope) {
+			hk.fire(line)
+		}
+	}
+}
+
+func (hk *hook) match(lvl Level, scope string) bool {
+	if lvl < hk.opts.MinLevel {
+		return false
+	}
+	if len(hk.opts.Scopes) == 0 {
+		return true
+	}
+	for _, s := range hk.opts.Scopes {
+		if strings.HasPrefix(scope, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (hk *hook) fire(line Line) {
+	if hk.queue == nil {
+		if e := hk.h.Fire(line); e != nil {
+			hk.report(e)
+		}
+		return
+	}
+	hk.mu.RLock()
+	defer hk.mu.RUnlock()
+	if hk.closed {
+		return
+	}
+	select {
+	case hk.queue <- line:
+	default:
+		atomic.AddInt64(&hk.dropped, 1)
+	}
+}
+
+func (hk *hook) run() {
+	defer close(hk.done)
+	for line := range hk.queue {
+		if e := hk.h.Fire(line); e != nil {
+			hk.report(e)
+		}
+		if n := atomic.SwapInt64(&hk.dropped, 0); n > 0 {
+			hk.report(errors.New("hook queue full, dropped " + strconv.FormatInt(n, 10) + " lines"))
+		}
+	}
+}
+
+// fatalHookTimeout - how long Fatal waits for async hooks before exiting
+var fatalHookTimeout = 5 * time.Second
+
+// drainHooks - closes the async hooks so queued lines, such as a fatal line,
+// are fired, waits at most timeout for slow hooks
+func (c *core) drainHooks(timeout time.Duration) {
+	if c == nil {
+		return
+	}
+	c.mu.RLock()
+	hooks := c.hooks
+	c.mu.RUnlock()
+	// closed concurrently so one stuck hook does not hold up the others
+	var wg sync.WaitGroup
+	for _, hk := range hooks {
+		if hk.queue != nil {
+			wg.Add(1)
+			go func(hk *hook) {
+				defer wg.Done()
+				hk.Close()
+			}(hk)
+		}
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
+
+// Close - fires every queued line and stops the goroutine
+func (hk *hook) Close() error {
+	hk.mu.Lock()
+	if !hk.closed {
+		hk.closed = true
+		close(hk.queue)
+	}
+	hk.mu.Unlock()
+	<-hk.done
+	return nil
+}
+
+// Webhook - hook posting each line as json to a url
+type Webhook struct {
+	url     string
+	client  *http.Client
+	headers map[string]string
+}
+
+// NewWebhook - creates a webhook hook, a client with a 5 second timeout is
+// used when client is nil, headers are added to every request
+func NewWebhook(url string, client *http.Client, headers map[string]string) *Webhook {
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &Webhook{url: url, client: client, headers: headers}
+}
+
+// Fire - posts the line, any status other than 2xx is an error
+func (wh *Webhook) Fire(line Line) error {
+	body, e := json.Marshal(line)
+	if e != nil {
+		return e
+	}
+	req, e := http.NewRequestWithContext(context.Background(), http.MethodPost, wh.url, bytes.NewReader(body))
+	if e != nil {
+		return e
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range wh.headers {
+		req.Header.Set(k, v)
+	}
+	res, e := wh.client.Do(req)
+	if e != nil {
+		return e
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return errors.New("webhook returned status " + strconv.Itoa(res.StatusCode))
+	}
+	return nil
+}
+
+// Counter - hook counting lines per level and scope, useful for metrics and
+// tests
+type Counter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// NewCounter - creates an empty counter
+func NewCounter() *Counter {
+	return &Counter{counts: map[string]int{}}
+}
+
+// Fire - counts the line
+func (c *Counter) Fire(line Line) error {
+	c.mu.Lock()
+	c.counts[line.Level+" "+line.Scope]++
+	c.mu.Unlock()
+	return nil
+}
+
+// Count - lines fired with the level and scope
+func (c *Counter) Count(lvl Level, scope string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[lvl.String()+" "+scope]
+}
+
+// Total - lines fired with the level, whatever the scope
+func (c *Counter) Total(lvl Level) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	prefix := lvl.String() + " "
+	for k, v := range c.counts {
+		if strings.HasPrefix(k, prefix) {
+			n += v
+		}
+	}
+	return n
+}
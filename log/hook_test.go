@@ -0,0 +1,125 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHooks(t *testing.T) {
+	l, _ := New("")
+	var buf bytes.Buffer
+	l.SetOutput(&buf, &buf)
+	l.RedactFields("password")
+
+	counter := NewCounter()
+	l.AddHook(counter, HookOptions{MinLevel: LevelError})
+	var fired []Line
+	l.AddHook(HookFunc(func(line Line) error {
+		fired = append(fired, line)
+		return nil
+	}), HookOptions{MinLevel: LevelTrace, Scopes: []string{"orders."}})
+	l.AddHook(HookFunc(func(line Line) error {
+		return errors.New("hook down")
+	}), HookOptions{MinLevel: LevelFatal})
+
+	l.Out("orders.MONGO", "info")
+	l.ErrorFields("orders.MONGO", errors.New("failed"), String("password", "x"))
+	l.Error("redis.GET", errors.New("failed"))
+
+	if counter.Count(LevelError, "orders.MONGO") != 1 || counter.Total(LevelError) != 2 || counter.Total(LevelInfo) != 0 {
+		t.Errorf("unexpected counts %v", counter.counts)
+	}
+	if len(fired) != 2 || fired[1].Stack == "" || fired[1].Fields[0].Value() != Redacted {
+		t.Errorf("unexpected fired lines %+v", fired)
+	}
+
+	orig := exit
+	exit = func(int) {}
+	defer func() { exit = orig }()
+	l.Fatal("APP", errors.New("bye"))
+	if !strings.Contains(buf.String(), `"scope":"LOG_HOOK","msg":"hook down"`) {
+		t.Errorf("expected hook error to be written, got %s", buf.String())
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var mu sync.Mutex
+	var received []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "t" || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var line map[string]interface{}
+		json.Unmarshal(body, &line)
+		mu.Lock()
+		received = append(received, line)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	l, _ := New("")
+	var buf bytes.Buffer
+	l.SetOutput(&buf, &buf)
+	l.AddHook(NewWebhook(srv.URL, nil, map[string]string{"X-Token": "t"}), HookOptions{MinLevel: LevelError, Async: true})
+	l.AddHook(NewWebhook(srv.URL, nil, nil), HookOptions{MinLevel: LevelError, Async: true})
+	l.Out("APP", "not forwarded")
+	l.ErrorFields("APP", errors.New("forwarded"), Int("status", 500))
+	l.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 1 || received[0]["msg"] != "forwarded" || received[0]["status"] != float64(500) || received[0]["level"] != "error" {
+		t.Errorf("unexpected webhook calls %v", received)
+	}
+	if !strings.Contains(buf.String(), "webhook returned status 401") {
+		t.Errorf("expected webhook error, got %s", buf.String())
+	}
+	// lines after close are not queued
+	l.Error("APP", errors.New("after close"))
+}
+
+func TestFatalDrainsAsyncHooks(t *testing.T) {
+	orig, origTimeout := exit, fatalHookTimeout
+	defer func() { exit, fatalHookTimeout = orig, origTimeout }()
+	exit = func(int) {}
+	fatalHookTimeout = 50 * time.Millisecond
+
+	l, _ := New("")
+	l.SetOutput(io.Discard, io.Discard)
+	var mu sync.Mutex
+	var fired []string
+	block := make(chan struct{})
+	defer close(block)
+	l.AddHook(HookFunc(func(line Line) error {
+		<-block
+		return nil
+	}), HookOptions{MinLevel: LevelFatal, Async: true})
+
+	l.AddHook(HookFunc(func(line Line) error {
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		fired = append(fired, line.Msg)
+		mu.Unlock()
+		return nil
+	}), HookOptions{MinLevel: LevelError, Async: true})
+	t1 := time.Now()
+	l.Fatal("APP", errors.New("bye"))
+	if time.Since(t1) > time.Second {
+		t.Error("a stuck hook should not delay exit past the timeout")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(fired) != 1 || fired[0] != "bye" {
+		t.Errorf("the fatal line should reach the hook before exit, got %v", fired)
+	}
+}
@@ -0,0 +1,167 @@
+package log
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// Level - severity of a log line, lines below the minimum level of a logger are dropped
+type Level int32
+
+const (
+	// LevelTrace - very fine grained diagnostic output
+	LevelTrace Level = iota - 2
+	// LevelDebug - diagnostic output, off in production by default
+	LevelDebug
+	// LevelInfo - normal operational output, this is the zero value
+	LevelInfo
+	// LevelWarn - something unexpected that the application recovered from
+	LevelWarn
+	// LevelError - a failure that needs attention
+	LevelError
+	// LevelFatal - a failure after which the process exits
+	LevelFatal
+	// LevelOff - used as a minimum level to silence the logger completely
+	LevelOff
+)
+
+// String - returns the lowercase name of the level
+func (lvl Level) String() string {
+	switch lvl {
+	case LevelTrace:
+		return "trace"
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	case LevelFatal:
+		return "fatal"
+	case LevelOff:
+		return "off"
+	}
+	return "unknown"
+}
+
+// ParseLevel - converts a level name (case insensitive) to a Level
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "trace":
+		return LevelTrace, nil
+	case "debug":
+		return LevelDebug, nil
+	case "info", "":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	case "fatal":
+		return LevelFatal, nil
+	case "off", "none":
+		return LevelOff, nil
+	}
+	return LevelInfo, errors.New("Invalid log level")
+}
+
+// levelOf - maps the legacy log types accepted by New onto a minimum level,
+// "erroronly" keeps the default level as it only mutes Out, see Enabled
+func levelOf(logtype string) Level {
+	if logtype == "empty" {
+		return LevelOff
+	}
+	if os.Getenv("GO_ENV") == "production" {
+		return LevelInfo
+	}
+	return LevelDebug
+}
+
+// AtomicLevel - minimum level that can be changed safely while logging,
+// shared by every logger using it
+type AtomicLevel struct {
+	v int32
+}
+
+// NewAtomicLevel - creates a level holder set to lvl
+func NewAtomicLevel(lvl Level) *AtomicLevel {
+	return &AtomicLevel{v: int32(lvl)}
+}
+
+// Level - returns the current level
+func (a *AtomicLevel) Level() Level {
+	return Level(atomic.LoadInt32(&a.v))
+}
+
+// SetLevel - changes the level and returns the previous one
+func (a *AtomicLevel) SetLevel(lvl Level) Level {
+	return Level(atomic.SwapInt32(&a.v, int32(lvl)))
+}
+
+// step - moves the level by delta within trace and off, returns the previous
+// and new level
+func (a *AtomicLevel) step(delta int32) (Level, Level) {
+	for {
+		old := atomic.LoadInt32(&a.v)
+		next := old + delta
+		if next < int32(LevelTrace) {
+			next = int32(LevelTrace)
+		}
+		if next > int32(LevelOff) {
+			next = int32(LevelOff)
+		}
+		if atomic.CompareAndSwapInt32(&a.v, old, next) {
+			return Level(old), Level(next)
+		}
+	}
+}
+
+// SetLevel - changes the minimum level of the logger, the change is visible
+// to every copy of the logger as they share the same level
+func (l *Log) SetLevel(lvl Level) {
+	if l.level == nil {
+		l.level = NewAtomicLevel(lvl)
+		return
+	}
+	l.level.SetLevel(lvl)
+}
+
+// SetAtomicLevel - makes the logger use a level shared with other loggers
+func (l *Log) SetAtomicLevel(a *AtomicLevel) {
+	l.level = a
+}
+
+// AtomicLevel - returns the level holder of the logger, creating it for a
+// zero value logger
+func (l *Log) AtomicLevel() *AtomicLevel {
+	if l.level == nil {
+		l.level = NewAtomicLevel(LevelInfo)
+	}
+	return l.level
+}
+
+// GetLevel - returns the current minimum level of the logger
+func (l Log) GetLevel() Level {
+	if l.level == nil {
+		return LevelInfo
+	}
+	return l.level.Level()
+}
+
+// Enabled - returns true if a line of the given level would be written
+func (l Log) Enabled(lvl Level) bool {
+	if lvl == LevelInfo && l.config == "erroronly" {
+		return false
+	}
+	return lvl < LevelOff && lvl >= l.GetLevel()
+}
+
+// audit - records a level change whatever the level is
+func (l Log) audit(from Level, to Level, source string) {
+	l.emit(LevelWarn, l.scoped("LOG_LEVEL"), "level changed from "+from.String()+" to "+to.String(), nil,
+		[]Field{String("from", from.String()), String("to", to.String()), String("source", source)})
+}
@@ -0,0 +1,55 @@
+package log
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// levelPayload - body of the level handler
+type levelPayload struct {
+	Level string `json:"level"`
+}
+
+// LevelHandler - returns an http.Handler reading the level with GET and
+// changing it with PUT or POST, given as {"level":"debug"} or ?level=debug,
+// changes are written as audit lines, mount it on a router, e.g.
+// rtr.Engine.Handle("/loglevel", l.LevelHandler())
+func (l *Log) LevelHandler() http.Handler {
+	a := l.AtomicLevel()
+	cp := *l
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			name := r.URL.Query().Get("level")
+			if name == "" {
+				var p levelPayload
+				if e := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&p); e != nil {
+					writeLevel(w, http.StatusBadRequest, levelPayload{Level: "invalid request body"})
+					return
+				}
+				name = p.Level
+			}
+			lvl, e := ParseLevel(name)
+			if e != nil || strings.TrimSpace(name) == "" {
+				writeLevel(w, http.StatusBadRequest, levelPayload{Level: "invalid level " + name})
+				return
+			}
+			if old := a.SetLevel(lvl); old != lvl {
+				cp.audit(old, lvl, "http "+r.RemoteAddr)
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			writeLevel(w, http.StatusMethodNotAllowed, levelPayload{Level: a.Level().String()})
+			return
+		}
+		writeLevel(w, http.StatusOK, levelPayload{Level: a.Level().String()})
+	})
+}
+
+func writeLevel(w http.ResponseWriter, status int, p levelPayload) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(p)
+}
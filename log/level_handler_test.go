@@ -0,0 +1,45 @@
+package log
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLevelHandler(t *testing.T) {
+	l, _ := New("")
+	l.SetLevel(LevelInfo)
+	var buf bytes.Buffer
+	l.SetOutput(&buf, &buf)
+	h := l.LevelHandler()
+
+	do := func(method string, target string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	if w := do(http.MethodGet, "/", ""); w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != `{"level":"info"}` {
+		t.Errorf("unexpected GET %d %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPut, "/", `{"level":"debug"}`); w.Code != http.StatusOK || l.GetLevel() != LevelDebug {
+		t.Errorf("unexpected PUT %d %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(buf.String(), `"scope":"LOG_LEVEL","msg":"level changed from info to debug"`) {
+		t.Errorf("expected audit line, got %s", buf.String())
+	}
+	if w := do(http.MethodPost, "/?level=error", ""); w.Code != http.StatusOK || l.GetLevel() != LevelError {
+		t.Errorf("unexpected POST %d %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPut, "/", `{"level":"loud"}`); w.Code != http.StatusBadRequest || l.GetLevel() != LevelError {
+		t.Errorf("expected bad request, got %d", w.Code)
+	}
+	if w := do(http.MethodPut, "/", `not json`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected bad request, got %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "/", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected method not allowed, got %d", w.Code)
+	}
+}
@@ -1,30 +1,82 @@
 package log
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"os"
 	"time"
-	"errors"
-	"fmt"
-	"runtime/debug"
-	"encoding/json"
 )
 
 // Log - instance created when initializing logger
 type Log struct {
-	config	string
-	json	bool
+	config  string
+	json    bool
+	console bool
+	level   *AtomicLevel
+	core    *core
+	bound   *bound
+	name    string
 }
 
+// Logger - the logging methods used across go-lib, implemented by Log and
+// SlogAdapter (go 1.21 and later) so libraries can accept either
+type Logger interface {
+	Out(scope string, msg string)
+	Debug(scope string, msg string)
+	Error(scope string, err error)
+}
+
+var _ Logger = Log{}
+
 // Line - json struct to indicate a logger line
 type Line struct {
-	Ts	time.Time	`json:"ts"`
-	Scope	string		`json:"scope"`
-	Msg	string		`json:"msg"`
-	Stack	string		`json:"stack,omitempty"`
+	Ts    time.Time `json:"ts"`
+	Level string    `json:"level,omitempty"`
+	Scope string    `json:"scope"`
+	Msg   string    `json:"msg"`
+	Stack string    `json:"stack,omitempty"`
+	// Fields - written as top level keys between msg and stack
+	Fields []Field `json:"-"`
 }
 
+// MarshalJSON - encodes the line with its fields as top level keys, fields
+// using one of the keys above are prefixed with "fields."
+func (line Line) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	ts, e := line.Ts.MarshalJSON()
+	if e != nil {
+		return nil, e
+	}
+	buf.WriteString(`{"ts":`)
+	buf.Write(ts)
+	if line.Level != "" {
+		buf.WriteString(`,"level":`)
+		appendString(&buf, line.Level)
+	}
+	buf.WriteString(`,"scope":`)
+	appendString(&buf, line.Scope)
+	buf.WriteString(`,"msg":`)
+	appendString(&buf, line.Msg)
+	appendFields(&buf, line.Fields, true)
+	if line.Stack != "" {
+		buf.WriteString(`,"stack":`)
+		appendString(&buf, line.Stack)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// exit - used by Fatal, replaced in tests
+var exit = os.Exit
+
 // New - constructor to create an instance of the logger
 // returns the instance and error
+// the log types map onto minimum levels: "" and "standard" log from debug
+// (info when GO_ENV is production), "erroronly" does the same but never
+// writes Out and Info lines and "empty" logs nothing, use SetLevel to change
+// it at runtime, LOG_FORMAT is applied when valid, use NewWithOptions for
+// anything else
 func New(logtype string) (Log, error) {
 	var l Log
 	var e error
@@ -32,68 +84,166 @@ func New(logtype string) (Log, error) {
 		e = errors.New("Invalid log type")
 		return l, e
 	}
+	l, e = NewWithOptions(WithLevel(levelOf(logtype)))
 	l.config = logtype
-	l.json = true
+	l.formatOf(os.Getenv("LOG_FORMAT"))
 	return l, e
 }
 
-// Out - outputs to stdout
-func (l Log) Out(scope string, msg string) {
-	if l.config != "empty" && l.config != "erroronly" {
-		logPrint(scope, msg, l.json)
+// Trace - outputs tracing to stdout
+func (l Log) Trace(scope string, msg string) {
+	l.TraceFields(scope, msg)
+}
+
+// TraceFields - outputs tracing with additional fields to stdout
+func (l Log) TraceFields(scope string, msg string, fields ...Field) {
+	if l.Enabled(LevelTrace) {
+		l.print(LevelTrace, scope, msg, nil, fields)
 	}
 }
 
 // Debug - outputs debugging to stdout
 func (l Log) Debug(scope string, msg string) {
-	if os.Getenv("GO_ENV") == "production" {
-		return
+	l.DebugFields(scope, msg)
+}
+
+// DebugFields - outputs debugging with additional fields to stdout
+func (l Log) DebugFields(scope string, msg string, fields ...Field) {
+	if l.Enabled(LevelDebug) {
+		l.print(LevelDebug, scope, msg, nil, fields)
 	}
-	if l.config != "empty" {
-		logPrint(scope, msg, l.json)
+}
+
+// Info - outputs to stdout
+func (l Log) Info(scope string, msg string) {
+	l.InfoFields(scope, msg)
+}
+
+// InfoFields - outputs with additional fields to stdout
+func (l Log) InfoFields(scope string, msg string, fields ...Field) {
+	if l.Enabled(LevelInfo) {
+		l.print(LevelInfo, scope, msg, nil, fields)
+	}
+}
+
+// Out - outputs to stdout, same as Info
+func (l Log) Out(scope string, msg string) {
+	l.InfoFields(scope, msg)
+}
+
+// OutFields - outputs with additional fields to stdout, same as InfoFields
+func (l Log) OutFields(scope string, msg string, fields ...Field) {
+	l.InfoFields(scope, msg, fields...)
+}
+
+// Warn - outputs warnings to stdout
+func (l Log) Warn(scope string, msg string) {
+	l.WarnFields(scope, msg)
+}
+
+// WarnFields - outputs warnings with additional fields to stdout
+func (l Log) WarnFields(scope string, msg string, fields ...Field) {
+	if l.Enabled(LevelWarn) {
+		l.print(LevelWarn, scope, msg, nil, fields)
 	}
 }
 
 // Error - outputs to stderr
 func (l Log) Error(scope string, err error) {
-	if err != nil && l.config != "empty" {
-		ts := time.Now()
-		if !l.json {
-			fmt.Fprintln(os.Stderr, ts.Format(time.RFC3339), scope, err)
-			fmt.Fprintln(os.Stderr, string(debug.Stack()));
-		} else {
-			jline, e := log2Json(ts, scope, err.Error(), string(debug.Stack()))
-			if e != nil {
-				fmt.Fprintln(os.Stderr, ts.Format(time.RFC3339), scope, err.Error())
-			} else {
-				fmt.Fprintln(os.Stderr, jline)
-			}
-		}
+	l.ErrorFields(scope, err)
+}
+
+// ErrorFields - outputs to stderr with additional fields
+func (l Log) ErrorFields(scope string, err error, fields ...Field) {
+	if err != nil && l.Enabled(LevelError) {
+		l.print(LevelError, scope, err.Error(), err, fields)
+	}
+}
+
+// Fatal - outputs to stderr and exits the process with status 1
+func (l Log) Fatal(scope string, err error) {
+	l.FatalFields(scope, err)
+}
+
+// FatalFields - outputs to stderr with additional fields and exits the
+// process with status 1, after flushing and firing async hooks for up to 5
+// seconds so the fatal line reaches alerting
+func (l Log) FatalFields(scope string, err error, fields ...Field) {
+	if err != nil && l.Enabled(LevelFatal) {
+		l.print(LevelFatal, scope, err.Error(), err, fields)
 	}
+	l.Flush()
+	l.core.drainHooks(fatalHookTimeout)
+	exit(1)
 }
 
 // JSONDisable - used to internally turn off json logging
 func (l *Log) JSONDisable() {
 	l.json = false
+	l.console = false
 }
 
 // JSONEnable - used to internally turn on json logging
 func (l *Log) JSONEnable() {
 	l.json = true
+	l.console = false
 }
 
-func logPrint(scope string, msg string, j bool) {
-	ts := time.Now()
-	if !j {
-		fmt.Println(ts.Format(time.RFC3339), scope, msg)
-	} else {
-		jline, e := log2Json(ts, scope, msg, "")
-		if e != nil {
-			fmt.Println(ts.Format(time.RFC3339), scope, msg)
-		} else {
-			fmt.Println(jline)
+// print - writes a line unless it is dropped by sampling
+func (l Log) print(lvl Level, scope string, msg string, err error, fields []Field) {
+	scope = l.scoped(scope)
+	if !l.core.allow(lvl, scope) {
+		return
+	}
+	l.emit(lvl, scope, msg, err, fields)
+}
+
+// emit - encodes a line and writes it in a single call after firing hooks,
+// error and fatal lines carry a stack as configured by SetStack
+func (l Log) emit(lvl Level, scope string, msg string, err error, fields []Field) {
+	r := record{
+		ts:      l.core.clock(),
+		lvl:     lvl,
+		scope:   scope,
+		msg:     msg,
+		fields:  l.merge(fields),
+		json:    l.json,
+		console: l.console,
+	}
+	l.core.redact(&r)
+	if lvl >= LevelError {
+		r.stack = l.core.captureStack(err)
+	}
+	l.core.fire(r)
+	l.core.dispatch(r)
+}
+
+// encode - returns the line terminated by a newline, color only applies to
+// console lines
+func (r record) encode(color bool) []byte {
+	if r.console {
+		return r.encodeConsole(color)
+	}
+	if !r.json {
+		p := []byte(textLine(r.ts, r.scope, r.msg, r.fields) + "\n")
+		if r.stack != "" {
+			p = append(p, r.stack+"\n"...)
 		}
+		return p
+	}
+	jline, e := log2Json(r.ts, r.lvl, r.scope, r.msg, r.stack, r.fields)
+	if e != nil {
+		return []byte(textLine(r.ts, r.scope, r.msg, r.fields) + "\n")
+	}
+	return []byte(jline + "\n")
+}
+
+func textLine(ts time.Time, scope string, msg string, fields []Field) string {
+	line := ts.Format(time.RFC3339) + " " + scope + " " + msg
+	if len(fields) > 0 {
+		line += " " + fieldsText(fields)
 	}
+	return line
 }
 
 func isValid(logtype string) bool {
@@ -104,13 +254,15 @@ func isValid(logtype string) bool {
 	return false
 }
 
-func log2Json(ts time.Time, scope string, msg string, stack string) (string, error) {
+func log2Json(ts time.Time, lvl Level, scope string, msg string, stack string, fields []Field) (string, error) {
 	line := &Line{
-		Ts: ts,
-		Scope: scope,
-		Msg: msg,
+		Ts:     ts,
+		Level:  lvl.String(),
+		Scope:  scope,
+		Msg:    msg,
+		Fields: fields,
 	}
-	if (stack != "") {
+	if stack != "" {
 		line.Stack = stack
 	}
 	j, e := json.Marshal(line)
@@ -1,8 +1,341 @@
 package log
 
 import (
-        "testing"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
 )
 
 func TestMain(t *testing.T) {
 }
+
+func TestLevels(t *testing.T) {
+	t.Setenv("GO_ENV", "")
+	cases := map[string]Level{
+		"":          LevelDebug,
+		"standard":  LevelDebug,
+		"erroronly": LevelDebug,
+		"empty":     LevelOff,
+	}
+	for logtype, want := range cases {
+		l, e := New(logtype)
+		if e != nil {
+			t.Fatalf("New(%q): %v", logtype, e)
+		}
+		if got := l.GetLevel(); got != want {
+			t.Errorf("New(%q) level = %s, want %s", logtype, got, want)
+		}
+	}
+
+	// erroronly only mutes Out, as it always did
+	eo, _ := New("erroronly")
+	if eo.Enabled(LevelInfo) || !eo.Enabled(LevelDebug) || !eo.Enabled(LevelWarn) || !eo.Enabled(LevelError) {
+		t.Error("erroronly should mute info lines only")
+	}
+
+	t.Setenv("GO_ENV", "production")
+	l, _ := New("")
+	if l.Enabled(LevelDebug) {
+		t.Error("debug should be off in production by default")
+	}
+	// copies share the level so it can be changed at runtime
+	cp := l
+	l.SetLevel(LevelTrace)
+	if !cp.Enabled(LevelDebug) || !cp.Enabled(LevelTrace) {
+		t.Error("SetLevel should be visible to copies")
+	}
+	l.SetLevel(LevelOff)
+	if cp.Enabled(LevelFatal) {
+		t.Error("nothing should be enabled when level is off")
+	}
+
+	var zero Log
+	if zero.GetLevel() != LevelInfo || zero.Enabled(LevelDebug) {
+		t.Error("zero value logger should log from info")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, lvl := range []Level{LevelTrace, LevelDebug, LevelInfo, LevelWarn, LevelError, LevelFatal, LevelOff} {
+		got, e := ParseLevel(lvl.String())
+		if e != nil || got != lvl {
+			t.Errorf("ParseLevel(%q) = %s, %v", lvl.String(), got, e)
+		}
+	}
+	if _, e := ParseLevel("verbose"); e == nil {
+		t.Error("expected error for unknown level")
+	}
+}
+
+func TestLineFields(t *testing.T) {
+	ts := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	line := Line{
+		Ts:    ts,
+		Level: "info",
+		Scope: "HTTP",
+		Msg:   "done",
+		Fields: []Field{
+			String("method", "GET"),
+			Int("status", 200),
+			Float("ratio", 0.5),
+			Duration("latency", 1500*time.Microsecond),
+			Bool("cached", true),
+			Err(errors.New("boom")),
+			NamedErr("cause", nil),
+			Object("req", String("path", "/"), Int("size", 3)),
+			Any("tags", []string{"a"}),
+			String("msg", "clash"),
+		},
+	}
+	j, e := json.Marshal(line)
+	if e != nil {
+		t.Fatal(e)
+	}
+	want := `{"ts":"2023-01-02T03:04:05Z","level":"info","scope":"HTTP","msg":"done",` +
+		`"method":"GET","status":200,"ratio":0.5,"latency":1.5,"cached":true,"error":"boom",` +
+		`"cause":null,"req":{"path":"/","size":3},"tags":["a"],"fields.msg":"clash"}`
+	if string(j) != want {
+		t.Errorf("got  %s\nwant %s", j, want)
+	}
+
+	txt := fieldsText([]Field{String("a", "b"), Duration("d", time.Second), Object("o", Int("n", 1))})
+	if txt != `a=b d=1s o={"n":1}` {
+		t.Errorf("unexpected text fields %q", txt)
+	}
+}
+
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("fail")
+}
+
+func TestOutput(t *testing.T) {
+	l, _ := New("")
+	var out, errOut bytes.Buffer
+	cp := l
+	l.SetOutput(&out, &errOut)
+
+	cp.Out("SCOPE", "hello")
+	cp.Error("SCOPE", errors.New("bad"))
+	if out.Len() == 0 || errOut.Len() == 0 {
+		t.Fatal("SetOutput should be visible to copies")
+	}
+	var line map[string]interface{}
+	if e := json.Unmarshal(out.Bytes(), &line); e != nil || line["msg"] != "hello" {
+		t.Errorf("unexpected line %q: %v", out.String(), e)
+	}
+	if e := json.Unmarshal(errOut.Bytes(), &line); e != nil || line["msg"] != "bad" || line["stack"] == nil {
+		t.Errorf("unexpected error line %q: %v", errOut.String(), e)
+	}
+
+	var a, b bytes.Buffer
+	mw := MultiWriter(&a, failWriter{}, &b)
+	if _, e := mw.Write([]byte("x")); e == nil {
+		t.Error("expected error from failing writer")
+	}
+	if a.String() != "x" || b.String() != "x" {
+		t.Error("all writers should receive the write")
+	}
+
+	var zero Log
+	zero.SetOutput(&a, nil)
+	zero.Out("ZERO", "line")
+	if !strings.Contains(a.String(), "ZERO line") {
+		t.Errorf("zero value logger should write text lines, got %q", a.String())
+	}
+}
+
+func TestCtx(t *testing.T) {
+	l, _ := New("")
+	var out bytes.Buffer
+	l.SetOutput(&out, &out)
+
+	ctx := ContextWithRequestID(context.Background(), "req-1")
+	ctx = ContextWithTrace(ctx, "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7")
+	l.Ctx(ctx).OutFields("CTX", "hello", Int("n", 1))
+
+	var line map[string]interface{}
+	if e := json.Unmarshal(out.Bytes(), &line); e != nil {
+		t.Fatal(e)
+	}
+	if line["request_id"] != "req-1" || line["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" ||
+		line["span_id"] != "00f067aa0ba902b7" || line["n"] != float64(1) {
+		t.Errorf("unexpected line %s", out.String())
+	}
+
+	// a context without ids leaves the logger untouched
+	if l.Ctx(context.Background()) != l {
+		t.Error("expected same logger for empty context")
+	}
+	if RequestIDFromContext(nil) != "" {
+		t.Error("expected empty id for nil context")
+	}
+}
+
+func TestSampling(t *testing.T) {
+	l, _ := New("")
+	var out bytes.Buffer
+	l.SetOutput(&out, &out)
+	l.SetSampling(Sampling{First: 2, Thereafter: 3})
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	l.core.sampler.now = func() time.Time { return now }
+
+	for i := 0; i < 10; i++ {
+		l.Error("NOISY", errors.New("failed"))
+	}
+	l.Out("QUIET", "once")
+	// lines 1, 2, 5 and 8 of NOISY are written
+	if n := strings.Count(out.String(), `"scope":"NOISY"`); n != 4 {
+		t.Errorf("expected 4 sampled lines, got %d", n)
+	}
+	// a new interval resets the counters
+	now = now.Add(2 * time.Second)
+	out.Reset()
+	l.Error("NOISY", errors.New("failed"))
+	if out.Len() == 0 {
+		t.Error("expected line after interval reset")
+	}
+
+	out.Reset()
+	l.Close()
+	var line map[string]interface{}
+	if e := json.Unmarshal(out.Bytes(), &line); e != nil {
+		t.Fatalf("expected summary line, got %q", out.String())
+	}
+	dropped, _ := line["dropped"].(map[string]interface{})
+	if line["scope"] != "LOG_SAMPLING" || line["total"] != float64(6) || dropped["NOISY"] != float64(6) {
+		t.Errorf("unexpected summary %s", out.String())
+	}
+
+	rl, _ := New("")
+	out.Reset()
+	rl.SetOutput(&out, &out)
+	rl.SetSampling(Sampling{Rate: 1, Burst: 2})
+	rl.core.sampler.now = func() time.Time { return now }
+	for i := 0; i < 5; i++ {
+		rl.Out("RATE", "line")
+	}
+	if n := strings.Count(out.String(), `"scope":"RATE"`); n != 2 {
+		t.Errorf("expected burst of 2 lines, got %d", n)
+	}
+
+	// a zero config turns sampling off after reporting what was dropped
+	out.Reset()
+	rl.SetSampling(Sampling{})
+	if !strings.Contains(out.String(), `"scope":"LOG_SAMPLING"`) {
+		t.Errorf("expected summary when sampling is turned off, got %q", out.String())
+	}
+	out.Reset()
+	for i := 0; i < 5; i++ {
+		rl.Out("RATE", "line")
+	}
+	if n := strings.Count(out.String(), `"scope":"RATE"`); n != 5 {
+		t.Errorf("expected every line once sampling is off, got %d", n)
+	}
+	if rl.core.sampler != nil || len(rl.core.closers) != 0 {
+		t.Errorf("the sampler should be released, got %v", rl.core.closers)
+	}
+	rl.Close()
+}
+
+// gateWriter - blocks writes until the gate is opened
+type gateWriter struct {
+	gate chan struct{}
+	mu   sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (w *gateWriter) Write(p []byte) (int, error) {
+	<-w.gate
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *gateWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func TestAsync(t *testing.T) {
+	l, _ := New("")
+	w := &gateWriter{gate: make(chan struct{})}
+	l.SetOutput(w, w)
+	l.SetAsync(Async{Size: 2})
+	// the writer goroutine blocks on the first line, two more fill the
+	// buffer and the rest are dropped without blocking the caller
+	for i := 0; i < 10; i++ {
+		l.Out("ASYNC", "line")
+	}
+	close(w.gate)
+	l.Flush()
+	out := w.String()
+	if n := strings.Count(out, `"scope":"ASYNC"`); n < 1 || n > 3 {
+		t.Errorf("unexpected number of written lines %d", n)
+	}
+	if !strings.Contains(out, `"scope":"LOG_ASYNC"`) {
+		t.Errorf("expected dropped report, got %s", out)
+	}
+
+	b, _ := New("")
+	var buf bytes.Buffer
+	b.SetOutput(&buf, &buf)
+	b.SetAsync(Async{Size: 1, Overflow: OverflowBlock})
+	for i := 0; i < 100; i++ {
+		b.Out("BLOCK", "line")
+	}
+	b.Close()
+	if n := strings.Count(buf.String(), "\n"); n != 100 {
+		t.Errorf("expected all 100 lines after close, got %d", n)
+	}
+	// after close lines are written synchronously
+	b.Out("BLOCK", "sync")
+	b.Flush()
+	if !strings.Contains(buf.String(), `"msg":"sync"`) {
+		t.Error("expected synchronous write after close")
+	}
+}
+
+func TestChild(t *testing.T) {
+	l, _ := New("")
+	var buf bytes.Buffer
+	l.SetOutput(&buf, &buf)
+
+	orders := l.Named("orders").With(String("svc", "orders"))
+	mongo := orders.Named("mongo").With(Int("shard", 2))
+	mongo.OutFields("MONGO_FIND", "found", Int("n", 3))
+	var line map[string]interface{}
+	if e := json.Unmarshal(buf.Bytes(), &line); e != nil {
+		t.Fatal(e)
+	}
+	if line["scope"] != "orders.mongo.MONGO_FIND" || line["svc"] != "orders" || line["shard"] != float64(2) || line["n"] != float64(3) {
+		t.Errorf("unexpected line %s", buf.String())
+	}
+
+	// parents are not affected by children
+	buf.Reset()
+	orders.Out("", "parent")
+	if strings.Contains(buf.String(), "shard") || !strings.Contains(buf.String(), `"scope":"orders"`) {
+		t.Errorf("unexpected parent line %s", buf.String())
+	}
+	if l.Named("") != l || l.With() != l {
+		t.Error("empty name or fields should return the same logger")
+	}
+
+	// a later field replaces an earlier one with the same key
+	buf.Reset()
+	ctx := ContextWithRequestID(context.Background(), "a")
+	l.Ctx(ctx).Ctx(ctx).With(String("k", "1"), String("svc", "x")).With(String("svc", "y")).
+		OutFields("DUP", "once", String("k", "2"))
+	want := `"msg":"once","request_id":"a","k":"2","svc":"y"}`
+	if got := strings.TrimSpace(buf.String()); !strings.HasSuffix(got, want) {
+		t.Errorf("expected every key once, got %s", got)
+	}
+}
@@ -0,0 +1,197 @@
+package log
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Option - configures a logger created by NewWithOptions
+type Option func(l *Log) error
+
+// NewWithOptions - constructor to create an instance of the logger from
+// options applied in order, without options it behaves like New(""), json
+// lines from debug (info when GO_ENV is production) to stdout and stderr,
+// when an option fails the goroutines started by earlier options are stopped
+// with Close, writers given to WithOutput stay open as the caller owns them
+func NewWithOptions(opts ...Option) (Log, error) {
+	var l Log
+	l.json = true
+	l.SetLevel(levelOf(""))
+	l.core = newCore()
+	for _, opt := range opts {
+		if e := opt(&l); e != nil {
+			l.Close()
+			return l, e
+		}
+	}
+	return l, nil
+}
+
+// WithLevel - sets the minimum level
+func WithLevel(lvl Level) Option {
+	return func(l *Log) error {
+		if lvl < LevelTrace || lvl > LevelOff {
+			return errors.New("Invalid log level")
+		}
+		l.SetLevel(lvl)
+		return nil
+	}
+}
+
+// WithAtomicLevel - uses a level shared with other loggers
+func WithAtomicLevel(a *AtomicLevel) Option {
+	return func(l *Log) error {
+		if a == nil {
+			return errors.New("Invalid atomic level")
+		}
+		l.SetAtomicLevel(a)
+		return nil
+	}
+}
+
+// WithJSON - writes json lines when true and plain text lines when false
+func WithJSON(enabled bool) Option {
+	return func(l *Log) error {
+		if enabled {
+			l.JSONEnable()
+		} else {
+			l.JSONDisable()
+		}
+		return nil
+	}
+}
+
+// WithConsole - writes human readable lines, see ConsoleEnable
+func WithConsole() Option {
+	return func(l *Log) error {
+		l.ConsoleEnable()
+		return nil
+	}
+}
+
+// WithOutput - sets the writers, see SetOutput
+func WithOutput(out io.Writer, errOut io.Writer) Option {
+	return func(l *Log) error {
+		l.SetOutput(out, errOut)
+		return nil
+	}
+}
+
+// WithClock - sets the function returning the timestamp of lines, useful in
+// tests
+func WithClock(now func() time.Time) Option {
+	return func(l *Log) error {
+		if now == nil {
+			return errors.New("Invalid clock")
+		}
+		l.core.mu.Lock()
+		l.core.now = now
+		l.core.mu.Unlock()
+		return nil
+	}
+}
+
+// WithName - prefixes scopes, see Named
+func WithName(name string) Option {
+	return func(l *Log) error {
+		*l = l.Named(name)
+		return nil
+	}
+}
+
+// WithFields - adds fields to every line, see With
+func WithFields(fields ...Field) Option {
+	return func(l *Log) error {
+		*l = l.With(fields...)
+		return nil
+	}
+}
+
+// WithStackConfig - sets how stacks are captured, see SetStack
+func WithStackConfig(cfg StackConfig) Option {
+	return func(l *Log) error {
+		l.SetStack(cfg)
+		return nil
+	}
+}
+
+// WithSampling - enables sampling and rate limiting, see SetSampling
+func WithSampling(cfg Sampling) Option {
+	return func(l *Log) error {
+		l.SetSampling(cfg)
+		return nil
+	}
+}
+
+// WithAsync - enables asynchronous writes, see SetAsync
+func WithAsync(cfg Async) Option {
+	return func(l *Log) error {
+		l.SetAsync(cfg)
+		return nil
+	}
+}
+
+// WithHook - registers a hook, see AddHook
+func WithHook(h Hook, opts HookOptions) Option {
+	return func(l *Log) error {
+		l.AddHook(h, opts)
+		return nil
+	}
+}
+
+// WithRedaction - masks the fields with these keys and applies the
+// redactors, see RedactFields and AddRedactor
+func WithRedaction(keys []string, redactors ...Redactor) Option {
+	return func(l *Log) error {
+		l.RedactFields(keys...)
+		l.AddRedactor(redactors...)
+		return nil
+	}
+}
+
+// WithEnv - applies LOG_LEVEL (trace, debug, info, warn, error, fatal, off)
+// and LOG_FORMAT (json, text, console) when they are set, invalid values are
+// an error, place it last so the environment overrides the code
+func WithEnv() Option {
+	return func(l *Log) error {
+		if v, ok := os.LookupEnv("LOG_LEVEL"); ok && v != "" {
+			lvl, e := ParseLevel(v)
+			if e != nil {
+				return errors.New("Invalid LOG_LEVEL " + v)
+			}
+			l.SetLevel(lvl)
+		}
+		if v, ok := os.LookupEnv("LOG_FORMAT"); ok && v != "" {
+			if !validFormat(v) {
+				return errors.New("Invalid LOG_FORMAT " + v)
+			}
+			l.formatOf(v)
+		}
+		return nil
+	}
+}
+
+func validFormat(format string) bool {
+	switch strings.ToLower(format) {
+	case "json", "text", "console":
+		return true
+	}
+	return false
+}
+
+// clock - returns the time of a new line
+func (c *core) clock() time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	c.mu.RLock()
+	now := c.now
+	c.mu.RUnlock()
+	if now == nil {
+		return time.Now()
+	}
+	return now()
+}
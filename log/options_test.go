@@ -0,0 +1,97 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	var buf bytes.Buffer
+	ts := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	l, e := NewWithOptions(
+		WithLevel(LevelWarn),
+		WithOutput(&buf, &buf),
+		WithClock(func() time.Time { return ts }),
+		WithName("orders"),
+		WithFields(String("svc", "api")),
+	)
+	if e != nil {
+		t.Fatal(e)
+	}
+	l.Out("HTTP", "hidden")
+	l.Warn("HTTP", "slow")
+	want := `{"ts":"2023-01-02T03:04:05Z","level":"warn","scope":"orders.HTTP","msg":"slow","svc":"api"}` + "\n"
+	if buf.String() != want {
+		t.Errorf("got  %s\nwant %s", buf.String(), want)
+	}
+
+	buf.Reset()
+	l, _ = NewWithOptions(WithJSON(false), WithOutput(&buf, nil), WithClock(func() time.Time { return ts }))
+	l.Out("HTTP", "text")
+	if buf.String() != "2023-01-02T03:04:05Z HTTP text\n" {
+		t.Errorf("unexpected text line %q", buf.String())
+	}
+
+	if _, e := NewWithOptions(WithLevel(Level(42))); e == nil {
+		t.Error("expected error for invalid level")
+	}
+	if _, e := NewWithOptions(WithClock(nil)); e == nil {
+		t.Error("expected error for nil clock")
+	}
+	if _, e := New("invalid"); e == nil {
+		t.Error("expected error for invalid log type")
+	}
+}
+
+func TestWithEnv(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "ERROR")
+	t.Setenv("LOG_FORMAT", "console")
+	l, e := NewWithOptions(WithLevel(LevelDebug), WithEnv())
+	if e != nil {
+		t.Fatal(e)
+	}
+	if l.GetLevel() != LevelError || !l.console {
+		t.Errorf("environment should override the code, got %s console=%v", l.GetLevel(), l.console)
+	}
+
+	t.Setenv("LOG_LEVEL", "loud")
+	if _, e := NewWithOptions(WithEnv()); e == nil {
+		t.Error("expected error for invalid LOG_LEVEL")
+	}
+	t.Setenv("LOG_LEVEL", "")
+	t.Setenv("LOG_FORMAT", "xml")
+	if _, e := NewWithOptions(WithEnv()); e == nil {
+		t.Error("expected error for invalid LOG_FORMAT")
+	}
+	// New ignores invalid formats like before
+	if _, e := New(""); e != nil {
+		t.Error(e)
+	}
+}
+
+func TestNewWithOptionsCloseOnError(t *testing.T) {
+	l, e := NewWithOptions(
+		WithOutput(&bytes.Buffer{}, &bytes.Buffer{}),
+		WithAsync(Async{Size: 8}),
+		WithSampling(Sampling{First: 1, Interval: time.Hour}),
+		WithHook(NewCounter(), HookOptions{Async: true}),
+		WithLevel(Level(42)),
+	)
+	if e == nil {
+		t.Fatal("expected error for an invalid level")
+	}
+	select {
+	case <-l.core.hooks[0].done:
+	default:
+		t.Error("async hook should be stopped")
+	}
+	if l.core.async != nil || len(l.core.closers) != 0 {
+		t.Error("async writer should be stopped")
+	}
+	select {
+	case <-l.core.sampler.done:
+	default:
+		t.Error("sampling summary should be stopped")
+	}
+}
@@ -0,0 +1,290 @@
+package log
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupFormat - timestamp added to rotated files, sorts in time order
+const backupFormat = "20060102T150405.000"
+
+// RotateConfig - settings of a RotatingFile, zero values disable the feature
+type RotateConfig struct {
+	// MaxSize - rotate before a write would make the file exceed this many bytes
+	MaxSize int64
+	// Interval - rotate on wall clock boundaries, e.g. every hour or 24 hours
+	Interval time.Duration
+	// MaxBackups - number of rotated files to keep, 0 keeps all
+	MaxBackups int
+	// Compress - gzip rotated files in the background
+	Compress bool
+	// Perm - permission of new files, defaults to 0644
+	Perm os.FileMode
+	// OnError - receives errors of rotation, compression and cleanup, which
+	// happen outside of any caller, defaults to an error line on stderr
+	OnError func(e error)
+}
+
+// RotatingFile - io.WriteCloser writing to a file that is rolled by size or
+// time, safe for concurrent writers
+type RotatingFile struct {
+	path     string
+	cfg      RotateConfig
+	mu       sync.Mutex
+	file     *os.File
+	closed   bool
+	size     int64
+	deadline time.Time
+	now      func() time.Time
+	rename   func(from string, to string) error
+	// mill - serializes compression and cleanup of backups
+	mill sync.Mutex
+	wg   sync.WaitGroup
+}
+
+// NewRotatingFile - opens or creates the file at path for appending
+func NewRotatingFile(path string, cfg RotateConfig) (*RotatingFile, error) {
+	if path == "" {
+		return nil, errors.New("Invalid log file path")
+	}
+	if cfg.Perm == 0 {
+		cfg.Perm = 0644
+	}
+	if cfg.OnError == nil {
+		cfg.OnError = func(e error) {
+			std.Error("LOG_ROTATE", e)
+		}
+	}
+	rf := &RotatingFile{path: path, cfg: cfg, now: time.Now, rename: os.Rename}
+	if e := rf.open(); e != nil {
+		return nil, e
+	}
+	return rf, nil
+}
+
+// NewFile - constructor to create an instance of the logger writing all
+// levels to a rotating file, use Close on the logger to close the file
+func NewFile(logtype string, path string, cfg RotateConfig) (Log, error) {
+	l, e := New(logtype)
+	if e != nil {
+		return l, e
+	}
+	rf, e := NewRotatingFile(path, cfg)
+	if e != nil {
+		return l, e
+	}
+	l.SetOutput(rf, rf)
+	l.core.mu.Lock()
+	l.core.closers = append(l.core.closers, rf)
+	l.core.mu.Unlock()
+	return l, nil
+}
+
+// Write - writes p to the file, rotating it first if needed, a failed
+// rotation is reported to OnError and retried on the next write while p is
+// written to the current file
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if e := rf.ready(); e != nil {
+		return 0, e
+	}
+	if rf.due(int64(len(p))) {
+		if e := rf.rotate(); e != nil {
+			rf.cfg.OnError(e)
+			if rf.file == nil {
+				return 0, e
+			}
+		}
+	}
+	n, e := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, e
+}
+
+// Rotate - forces a rotation of the file
+func (rf *RotatingFile) Rotate() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if e := rf.ready(); e != nil {
+		return e
+	}
+	return rf.rotate()
+}
+
+// Close - closes the file and waits for pending compression
+func (rf *RotatingFile) Close() error {
+	rf.mu.Lock()
+	var e error
+	rf.closed = true
+	if rf.file != nil {
+		e = rf.file.Close()
+		rf.file = nil
+	}
+	rf.mu.Unlock()
+	rf.wg.Wait()
+	return e
+}
+
+// ready - reopens the file if a rotation left it closed, must be called with
+// mu held
+func (rf *RotatingFile) ready() error {
+	if rf.closed {
+		return os.ErrClosed
+	}
+	if rf.file == nil {
+		return rf.open()
+	}
+	return nil
+}
+
+func (rf *RotatingFile) due(n int64) bool {
+	if rf.cfg.MaxSize > 0 && rf.size > 0 && rf.size+n > rf.cfg.MaxSize {
+		return true
+	}
+	return rf.cfg.Interval > 0 && !rf.now().Before(rf.deadline)
+}
+
+func (rf *RotatingFile) open() error {
+	if e := os.MkdirAll(filepath.Dir(rf.path), 0755); e != nil {
+		return e
+	}
+	f, e := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, rf.cfg.Perm)
+	if e != nil {
+		return e
+	}
+	info, e := f.Stat()
+	if e != nil {
+		f.Close()
+		return e
+	}
+	rf.file = f
+	rf.size = info.Size()
+	if rf.cfg.Interval > 0 {
+		rf.deadline = rf.now().Truncate(rf.cfg.Interval).Add(rf.cfg.Interval)
+	}
+	return nil
+}
+
+// rotate - renames the current file to a timestamped backup and opens a new
+// one, on failure the current file is reopened so writes go on and the
+// deadline is kept so the rotation is retried, must be called with mu held
+func (rf *RotatingFile) rotate() error {
+	deadline := rf.deadline
+	rf.file.Close()
+	rf.file = nil
+	backup := rf.backupName(rf.now())
+	if e := rf.rename(rf.path, backup); e != nil && !os.IsNotExist(e) {
+		if e2 := rf.open(); e2 == nil {
+			rf.deadline = deadline
+		}
+		return e
+	}
+	if e := rf.open(); e != nil {
+		// move the backup back so the next write appends to it
+		rf.rename(backup, rf.path)
+		if e2 := rf.open(); e2 == nil {
+			rf.deadline = deadline
+		}
+		return e
+	}
+	rf.wg.Add(1)
+	go func() {
+		defer rf.wg.Done()
+		rf.mill.Lock()
+		defer rf.mill.Unlock()
+		if rf.cfg.Compress {
+			if e := compress(backup, rf.cfg.Perm); e != nil {
+				rf.cfg.OnError(e)
+			}
+		}
+		if e := rf.prune(); e != nil {
+			rf.cfg.OnError(e)
+		}
+	}()
+	return nil
+}
+
+func (rf *RotatingFile) split() (string, string) {
+	ext := filepath.Ext(rf.path)
+	return strings.TrimSuffix(rf.path, ext), ext
+}
+
+func (rf *RotatingFile) backupName(t time.Time) string {
+	prefix, ext := rf.split()
+	name := prefix + "-" + t.Format(backupFormat) + ext
+	// two rotations within the same millisecond would overwrite each other
+	for i := 1; ; i++ {
+		if _, e := os.Stat(name); os.IsNotExist(e) {
+			if _, e := os.Stat(name + ".gz"); os.IsNotExist(e) {
+				return name
+			}
+		}
+		name = prefix + "-" + t.Add(time.Duration(i)*time.Millisecond).Format(backupFormat) + ext
+	}
+}
+
+// backups - rotated files of this log, oldest first
+func (rf *RotatingFile) backups() []string {
+	prefix, ext := rf.split()
+	matches, _ := filepath.Glob(prefix + "-*" + ext + "*")
+	var list []string
+	for _, m := range matches {
+		stamp := strings.TrimSuffix(strings.TrimSuffix(m, ".gz"), ext)
+		stamp = strings.TrimPrefix(stamp, prefix+"-")
+		if _, e := time.Parse(backupFormat, stamp); e == nil {
+			list = append(list, m)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+// prune - removes the oldest backups above MaxBackups, returns the first error
+func (rf *RotatingFile) prune() error {
+	if rf.cfg.MaxBackups <= 0 {
+		return nil
+	}
+	var err error
+	list := rf.backups()
+	for len(list) > rf.cfg.MaxBackups {
+		if e := os.Remove(list[0]); e != nil && err == nil {
+			err = e
+		}
+		list = list[1:]
+	}
+	return err
+}
+
+// compress - gzips src next to it with permission perm and removes src on
+// success
+func compress(src string, perm os.FileMode) error {
+	in, e := os.Open(src)
+	if e != nil {
+		return e
+	}
+	defer in.Close()
+	out, e := os.OpenFile(src+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if e != nil {
+		return e
+	}
+	gz := gzip.NewWriter(out)
+	if _, e = io.Copy(gz, in); e == nil {
+		e = gz.Close()
+	}
+	if e2 := out.Close(); e == nil {
+		e = e2
+	}
+	if e != nil {
+		os.Remove(src + ".gz")
+		return e
+	}
+	return os.Remove(src)
+}
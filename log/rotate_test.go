@@ -0,0 +1,174 @@
+package log
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotateSize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	rf, e := NewRotatingFile(path, RotateConfig{MaxSize: 10, MaxBackups: 2})
+	if e != nil {
+		t.Fatal(e)
+	}
+	clock := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	rf.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+	for i := 0; i < 5; i++ {
+		if _, e := rf.Write([]byte("12345678\n")); e != nil {
+			t.Fatal(e)
+		}
+	}
+	if e := rf.Close(); e != nil {
+		t.Fatal(e)
+	}
+	if _, e := rf.Write([]byte("x")); e == nil {
+		t.Error("write after close should fail")
+	}
+	backups := rf.backups()
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups, got %v", backups)
+	}
+	b, _ := os.ReadFile(path)
+	if string(b) != "12345678\n" {
+		t.Errorf("unexpected current file %q", b)
+	}
+}
+
+func TestRotateIntervalCompress(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	clock := time.Date(2023, 1, 1, 0, 30, 0, 0, time.UTC)
+	rf := &RotatingFile{path: path, cfg: RotateConfig{Interval: time.Hour, Compress: true, Perm: 0600}, now: func() time.Time { return clock }, rename: os.Rename}
+	if e := rf.open(); e != nil {
+		t.Fatal(e)
+	}
+	rf.Write([]byte("first\n"))
+	clock = clock.Add(time.Hour)
+	rf.Write([]byte("second\n"))
+	rf.Close()
+
+	backups := rf.backups()
+	if len(backups) != 1 || !strings.HasSuffix(backups[0], ".log.gz") {
+		t.Fatalf("expected one compressed backup, got %v", backups)
+	}
+	info, e := os.Stat(backups[0])
+	if e != nil {
+		t.Fatal(e)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("the backup should keep the permission of the log, got %v", info.Mode())
+	}
+	f, _ := os.Open(backups[0])
+	defer f.Close()
+	gz, e := gzip.NewReader(f)
+	if e != nil {
+		t.Fatal(e)
+	}
+	b, _ := io.ReadAll(gz)
+	if string(b) != "first\n" {
+		t.Errorf("unexpected backup content %q", b)
+	}
+}
+
+func TestRotateFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	var errs []error
+	rf, e := NewRotatingFile(path, RotateConfig{MaxSize: 10, OnError: func(e error) {
+		errs = append(errs, e)
+	}})
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer rf.Close()
+	rf.rename = func(from string, to string) error {
+		return errors.New("disk full")
+	}
+	// writes go on to the current file while rotation fails
+	for _, line := range []string{"12345678\n", "abcdefgh\n"} {
+		if _, e := rf.Write([]byte(line)); e != nil {
+			t.Fatalf("write should not fail, got %v", e)
+		}
+	}
+	if len(errs) != 1 || errs[0].Error() != "disk full" {
+		t.Errorf("expected the rotation error to be reported, got %v", errs)
+	}
+	if e := rf.Rotate(); e == nil {
+		t.Error("expected the forced rotation to fail")
+	}
+
+	// the rotation is retried once renaming works again
+	rf.rename = os.Rename
+	if _, e := rf.Write([]byte("next\n")); e != nil {
+		t.Fatal(e)
+	}
+	if backups := rf.backups(); len(backups) != 1 {
+		t.Fatalf("expected the retried rotation, got %v", backups)
+	}
+	b, _ := os.ReadFile(rf.backups()[0])
+	if string(b) != "12345678\nabcdefgh\n" {
+		t.Errorf("no line should be lost, got %q", b)
+	}
+	b, _ = os.ReadFile(path)
+	if string(b) != "next\n" {
+		t.Errorf("unexpected current file %q", b)
+	}
+}
+
+func TestRotateCompressError(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	errc := make(chan error, 1)
+	rf, e := NewRotatingFile(path, RotateConfig{Compress: true, OnError: func(e error) {
+		errc <- e
+	}})
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer rf.Close()
+	rf.Write([]byte("first\n"))
+	// the backup disappears before it is compressed
+	rf.rename = func(from string, to string) error {
+		return os.Remove(from)
+	}
+	if e := rf.Rotate(); e != nil {
+		t.Fatal(e)
+	}
+	select {
+	case e := <-errc:
+		if !os.IsNotExist(e) {
+			t.Errorf("expected the compression error, got %v", e)
+		}
+	case <-time.After(time.Second):
+		t.Error("compression error not reported")
+	}
+}
+
+func TestNewFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "app.log")
+	l, e := NewFile("", path, RotateConfig{})
+	if e != nil {
+		t.Fatal(e)
+	}
+	l.Out("FILE", "hello")
+	if e := l.Close(); e != nil {
+		t.Fatal(e)
+	}
+	b, _ := os.ReadFile(path)
+	if !strings.Contains(string(b), `"msg":"hello"`) {
+		t.Errorf("unexpected file content %q", b)
+	}
+	if _, e := NewFile("bad", path, RotateConfig{}); e == nil {
+		t.Error("expected error for invalid log type")
+	}
+}
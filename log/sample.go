@@ -0,0 +1,219 @@
+package log
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Sampling - limits how many lines are written, zero values disable the
+// corresponding limit, fatal lines are never dropped
+type Sampling struct {
+	// First - lines per scope written unconditionally within each Interval
+	First int
+	// Thereafter - after First, only every Thereafter-th line of the scope is
+	// written within the Interval, 0 drops all of them
+	Thereafter int
+	// Interval - window of the per scope counters, defaults to 1 second
+	Interval time.Duration
+	// Rate - global lines per second allowed by a token bucket
+	Rate float64
+	// Burst - size of the token bucket, defaults to Rate rounded up
+	Burst int
+	// Report - how often a summary of dropped lines per scope is written,
+	// defaults to 1 minute, it is only written if lines were dropped
+	Report time.Duration
+}
+
+type sampler struct {
+	cfg      Sampling
+	mu       sync.Mutex
+	now      func() time.Time
+	counters map[string]*counter
+	tokens   float64
+	last     time.Time
+	dropped  map[string]int
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+type counter struct {
+	n     int
+	reset time.Time
+}
+
+// SetSampling - enables sampling and rate limiting of the logger, a summary
+// line with scope LOG_SAMPLING reports dropped lines, the change is visible to
+// every copy of the logger, use Close to stop the summary. A zero Sampling
+// turns sampling off again, e.g. once an incident is over, after writing the
+// summary of the lines dropped so far
+func (l *Log) SetSampling(cfg Sampling) {
+	if l.core == nil {
+		l.core = newCore()
+	}
+	if cfg == (Sampling{}) {
+		l.core.setSampler(nil)
+		return
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.Report <= 0 {
+		cfg.Report = time.Minute
+	}
+	if cfg.Rate > 0 && cfg.Burst <= 0 {
+		cfg.Burst = int(cfg.Rate)
+		if float64(cfg.Burst) < cfg.Rate {
+			cfg.Burst++
+		}
+	}
+	s := &sampler{
+		cfg:      cfg,
+		now:      time.Now,
+		counters: map[string]*counter{},
+		tokens:   float64(cfg.Burst),
+		dropped:  map[string]int{},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	s.last = s.now()
+	l.core.setSampler(s)
+	go s.run(*l)
+}
+
+// setSampler - replaces the sampler, nil disables sampling, the previous one
+// is closed so its summary is written and it is no longer closed with the
+// logger
+func (c *core) setSampler(s *sampler) {
+	c.mu.Lock()
+	old := c.sampler
+	c.sampler = s
+	closers := c.closers[:0]
+	for _, cl := range c.closers {
+		if cl != io.Closer(old) {
+			closers = append(closers, cl)
+		}
+	}
+	if s != nil {
+		closers = append(closers, s)
+	}
+	c.closers = closers
+	c.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+}
+
+// allow - returns false if the line has to be dropped
+func (c *core) allow(lvl Level, scope string) bool {
+	if c == nil || lvl >= LevelFatal {
+		return true
+	}
+	c.mu.RLock()
+	s := c.sampler
+	c.mu.RUnlock()
+	if s == nil {
+		return true
+	}
+	return s.allow(scope)
+}
+
+func (s *sampler) allow(scope string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if s.cfg.First > 0 || s.cfg.Thereafter > 0 {
+		c, ok := s.counters[scope]
+		if !ok {
+			c = &counter{}
+			s.counters[scope] = c
+		}
+		if !now.Before(c.reset) {
+			c.n = 0
+			c.reset = now.Add(s.cfg.Interval)
+		}
+		c.n++
+		if c.n > s.cfg.First && (s.cfg.Thereafter <= 0 || (c.n-s.cfg.First)%s.cfg.Thereafter != 0) {
+			s.dropped[scope]++
+			return false
+		}
+	}
+	if s.cfg.Rate > 0 {
+		// a clock going backwards must not take tokens away
+		if now.After(s.last) {
+			s.tokens += now.Sub(s.last).Seconds() * s.cfg.Rate
+			if s.tokens > float64(s.cfg.Burst) {
+				s.tokens = float64(s.cfg.Burst)
+			}
+		}
+		s.last = now
+		if s.tokens < 1 {
+			s.dropped[scope]++
+			return false
+		}
+		s.tokens--
+	}
+	return true
+}
+
+// report - returns the dropped counts since the last report and resets them,
+// counters of idle scopes are removed so the map does not grow forever
+func (s *sampler) report() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for scope, c := range s.counters {
+		if !now.Before(c.reset) {
+			delete(s.counters, scope)
+		}
+	}
+	if len(s.dropped) == 0 {
+		return nil
+	}
+	dropped := s.dropped
+	s.dropped = map[string]int{}
+	return dropped
+}
+
+func (s *sampler) run(l Log) {
+	defer close(s.done)
+	t := time.NewTicker(s.cfg.Report)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			l.summary(s.report())
+		case <-s.stop:
+			l.summary(s.report())
+			return
+		}
+	}
+}
+
+// Close - stops the summary after writing the last one
+func (s *sampler) Close() error {
+	s.once.Do(func() { close(s.stop) })
+	<-s.done
+	return nil
+}
+
+// summary - writes the dropped counts bypassing the sampler
+func (l Log) summary(dropped map[string]int) {
+	if len(dropped) == 0 || !l.Enabled(LevelWarn) {
+		return
+	}
+	scopes := make([]string, 0, len(dropped))
+	for scope := range dropped {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+	total := 0
+	fields := make([]Field, 0, len(scopes))
+	for _, scope := range scopes {
+		total += dropped[scope]
+		fields = append(fields, Int(scope, dropped[scope]))
+	}
+	l.emit(LevelWarn, "LOG_SAMPLING", "dropped log lines", nil, []Field{Int("total", total), Object("dropped", fields...)})
+}
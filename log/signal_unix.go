@@ -0,0 +1,48 @@
+//go:build !windows
+
+package log
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// HandleLevelSignals - makes the logger more verbose by one level on SIGUSR1
+// and less verbose on SIGUSR2, changes are written as audit lines, call the
+// returned function to stop handling the signals, it waits for a change in
+// progress to be written
+func (l *Log) HandleLevelSignals() func() {
+	a := l.AtomicLevel()
+	cp := *l
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	signal.Notify(ch, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		defer close(exited)
+		for {
+			select {
+			case sig := <-ch:
+				delta := int32(1)
+				if sig == syscall.SIGUSR1 {
+					delta = -1
+				}
+				if from, to := a.step(delta); from != to {
+					cp.audit(from, to, "signal "+sig.String())
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+			<-exited
+		})
+	}
+}
@@ -0,0 +1,38 @@
+//go:build !windows
+
+package log
+
+import (
+	"bytes"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestHandleLevelSignals(t *testing.T) {
+	l, _ := New("")
+	l.SetLevel(LevelInfo)
+	var buf bytes.Buffer
+	l.SetOutput(&buf, &buf)
+	stop := l.HandleLevelSignals()
+	defer stop()
+
+	wait := func(want Level) {
+		t.Helper()
+		for i := 0; i < 100 && l.GetLevel() != want; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if got := l.GetLevel(); got != want {
+			t.Fatalf("level = %s, want %s", got, want)
+		}
+	}
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+	wait(LevelDebug)
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR2)
+	wait(LevelInfo)
+	stop()
+	if !strings.Contains(buf.String(), `"source":"signal user defined signal 1"`) {
+		t.Errorf("expected audit line, got %s", buf.String())
+	}
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package log
+
+// HandleLevelSignals - SIGUSR1 and SIGUSR2 do not exist on windows, this
+// does nothing and returns a no-op stop function
+func (l *Log) HandleLevelSignals() func() {
+	return func() {}
+}
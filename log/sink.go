@@ -0,0 +1,138 @@
+package log
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// core - state shared by all copies of a logger
+type core struct {
+	mu     sync.RWMutex
+	out    io.Writer
+	errOut io.Writer
+	// outColor, errColor - whether the writers are terminals
+	outColor bool
+	errColor bool
+	// wmu - serializes writes so lines from concurrent callers never
+	// interleave, even when out and errOut are the same writer
+	wmu sync.Mutex
+	// closers - outputs opened by the logger itself, closed by Close
+	closers []io.Closer
+	sampler *sampler
+	async   *async
+	stack   StackConfig
+	// redaction - replaced as a whole on change, nil when disabled
+	redaction *redaction
+	hooks     []*hook
+	// now - clock set by WithClock, time.Now when nil
+	now func() time.Time
+}
+
+func newCore() *core {
+	return &core{
+		out:      os.Stdout,
+		errOut:   os.Stderr,
+		outColor: isTerminal(os.Stdout),
+		errColor: isTerminal(os.Stderr),
+	}
+}
+
+// SetOutput - changes where lines are written, out receives trace to warn
+// and errOut receives error and fatal, a nil writer restores the default
+// os.Stdout or os.Stderr, the change is visible to every copy of the logger
+func (l *Log) SetOutput(out io.Writer, errOut io.Writer) {
+	if l.core == nil {
+		l.core = newCore()
+	}
+	if out == nil {
+		out = os.Stdout
+	}
+	if errOut == nil {
+		errOut = os.Stderr
+	}
+	l.core.mu.Lock()
+	l.core.out = out
+	l.core.errOut = errOut
+	l.core.outColor = isTerminal(out)
+	l.core.errColor = isTerminal(errOut)
+	l.core.mu.Unlock()
+}
+
+// Close - closes outputs opened by the logger, such as the file of NewFile,
+// and stops background work in reverse order of creation, writers passed to
+// SetOutput are left to the caller
+func (l Log) Close() error {
+	if l.core == nil {
+		return nil
+	}
+	l.core.mu.Lock()
+	closers := l.core.closers
+	l.core.closers = nil
+	l.core.mu.Unlock()
+	var err error
+	for i := len(closers) - 1; i >= 0; i-- {
+		if e := closers[i].Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// writer - returns the writer for the level and whether it is a terminal,
+// defaults for a zero value logger
+func (c *core) writer(lvl Level) (io.Writer, bool) {
+	if c == nil {
+		if lvl >= LevelError {
+			return os.Stderr, isTerminal(os.Stderr)
+		}
+		return os.Stdout, isTerminal(os.Stdout)
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if lvl >= LevelError {
+		return c.errOut, c.errColor
+	}
+	return c.out, c.outColor
+}
+
+// write - encodes the record and writes it in a single call
+func (c *core) write(r record) {
+	w, color := c.writer(r.lvl)
+	p := r.encode(color)
+	if c == nil {
+		w.Write(p)
+		return
+	}
+	c.wmu.Lock()
+	w.Write(p)
+	c.wmu.Unlock()
+}
+
+// MultiWriter - fans out every write to all writers, unlike io.MultiWriter a
+// failing writer does not stop the others, the first error is returned
+func MultiWriter(writers ...io.Writer) io.Writer {
+	w := make([]io.Writer, len(writers))
+	copy(w, writers)
+	return multiWriter(w)
+}
+
+type multiWriter []io.Writer
+
+func (mw multiWriter) Write(p []byte) (int, error) {
+	var err error
+	for _, w := range mw {
+		n, e := w.Write(p)
+		if e == nil && n != len(p) {
+			e = io.ErrShortWrite
+		}
+		if e != nil && err == nil {
+			err = e
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
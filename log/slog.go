@@ -0,0 +1,226 @@
+//go:build go1.21
+
+// slog support needs go 1.21, the rest of the package builds with the go
+// version of go.mod, on older toolchains SlogHandler, SlogAdapter and
+// FromSlog are not available
+
+package log
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogHandler - slog.Handler writing lines in the same format as Log, the
+// string attribute "scope" at the top level becomes the scope of the line,
+// needs go 1.21
+type SlogHandler struct {
+	l     Log
+	scope string
+	goas  []groupOrAttrs
+}
+
+// groupOrAttrs - a group opened by WithGroup or attributes added by WithAttrs
+type groupOrAttrs struct {
+	group string
+	attrs []slog.Attr
+}
+
+// NewSlogHandler - creates a handler writing through l, so level, outputs,
+// sampling and every other setting of l apply, scope is used for records
+// without a "scope" attribute
+func NewSlogHandler(l Log, scope string) *SlogHandler {
+	return &SlogHandler{l: l, scope: scope}
+}
+
+// Enabled - implements slog.Handler
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.l.Enabled(fromSlogLevel(level))
+}
+
+// Handle - implements slog.Handler, request and trace ids in ctx are added
+// like Log.Ctx does
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	scope := h.scope
+	var err error
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	if !h.inGroup() {
+		scope, attrs = takeScope(scope, attrs)
+	}
+	fields := h.build(h.goas, attrsFields(attrs, &err))
+	lvl := fromSlogLevel(r.Level)
+	if lvl >= LevelFatal {
+		// fatal exits the process, slog callers never expect that
+		lvl = LevelError
+	}
+	h.l.Ctx(ctx).print(lvl, scope, r.Message, err, fields)
+	return nil
+}
+
+// WithAttrs - implements slog.Handler
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	if !h.inGroup() {
+		h2.scope, attrs = takeScope(h.scope, attrs)
+		if len(attrs) == 0 {
+			return &h2
+		}
+	}
+	h2.goas = append(h.goas[:len(h.goas):len(h.goas)], groupOrAttrs{attrs: attrs})
+	return &h2
+}
+
+// WithGroup - implements slog.Handler
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.goas = append(h.goas[:len(h.goas):len(h.goas)], groupOrAttrs{group: name})
+	return &h2
+}
+
+// inGroup - true once WithGroup was called, attributes then belong to the group
+func (h *SlogHandler) inGroup() bool {
+	for _, g := range h.goas {
+		if g.group != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// build - nests the fields of the record inside the groups opened so far
+func (h *SlogHandler) build(goas []groupOrAttrs, record []Field) []Field {
+	var fields []Field
+	for i, g := range goas {
+		if g.group != "" {
+			inner := h.build(goas[i+1:], record)
+			if len(inner) > 0 {
+				fields = append(fields, Object(g.group, inner...))
+			}
+			return fields
+		}
+		fields = append(fields, attrsFields(g.attrs, nil)...)
+	}
+	return append(fields, record...)
+}
+
+// takeScope - removes a string "scope" attribute and returns its value
+func takeScope(scope string, attrs []slog.Attr) (string, []slog.Attr) {
+	rest := attrs[:0:0]
+	for _, a := range attrs {
+		if a.Key == "scope" && a.Value.Kind() == slog.KindString {
+			scope = a.Value.String()
+			continue
+		}
+		rest = append(rest, a)
+	}
+	return scope, rest
+}
+
+// attrsFields - converts attributes to fields, the first error value is
+// stored in err when it is not nil
+func attrsFields(attrs []slog.Attr, err *error) []Field {
+	fields := make([]Field, 0, len(attrs))
+	for _, a := range attrs {
+		v := a.Value.Resolve()
+		switch v.Kind() {
+		case slog.KindString:
+			fields = append(fields, String(a.Key, v.String()))
+		case slog.KindInt64:
+			fields = append(fields, Int64(a.Key, v.Int64()))
+		case slog.KindUint64:
+			fields = append(fields, Any(a.Key, v.Uint64()))
+		case slog.KindFloat64:
+			fields = append(fields, Float(a.Key, v.Float64()))
+		case slog.KindBool:
+			fields = append(fields, Bool(a.Key, v.Bool()))
+		case slog.KindDuration:
+			fields = append(fields, Duration(a.Key, v.Duration()))
+		case slog.KindTime:
+			fields = append(fields, Time(a.Key, v.Time()))
+		case slog.KindGroup:
+			inner := attrsFields(v.Group(), err)
+			if len(inner) == 0 {
+				continue
+			}
+			if a.Key == "" {
+				// an unnamed group is inlined
+				fields = append(fields, inner...)
+			} else {
+				fields = append(fields, Object(a.Key, inner...))
+			}
+		default:
+			if a.Key == "" {
+				continue
+			}
+			if e, ok := v.Any().(error); ok {
+				if err != nil && *err == nil {
+					*err = e
+				}
+				fields = append(fields, NamedErr(a.Key, e))
+				continue
+			}
+			fields = append(fields, Any(a.Key, v.Any()))
+		}
+	}
+	return fields
+}
+
+func fromSlogLevel(level slog.Level) Level {
+	switch {
+	case level < slog.LevelDebug:
+		return LevelTrace
+	case level < slog.LevelInfo:
+		return LevelDebug
+	case level < slog.LevelWarn:
+		return LevelInfo
+	case level < slog.LevelError:
+		return LevelWarn
+	}
+	return LevelError
+}
+
+// SlogAdapter - wraps a *slog.Logger so it can be used where a Logger is
+// expected, the scope is passed as the "scope" attribute so a logger using
+// SlogHandler writes the same lines as Log, errors are passed as the "error"
+// attribute, needs go 1.21
+type SlogAdapter struct {
+	logger *slog.Logger
+}
+
+var _ Logger = SlogAdapter{}
+
+// FromSlog - creates an adapter for logger, slog.Default is used when nil
+func FromSlog(logger *slog.Logger) SlogAdapter {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return SlogAdapter{logger: logger}
+}
+
+// Out - logs msg at info level
+func (a SlogAdapter) Out(scope string, msg string) {
+	a.logger.Info(msg, slog.String("scope", scope))
+}
+
+// Debug - logs msg at debug level
+func (a SlogAdapter) Debug(scope string, msg string) {
+	a.logger.Debug(msg, slog.String("scope", scope))
+}
+
+// Error - logs the error message at error level, nil errors are ignored
+func (a SlogAdapter) Error(scope string, err error) {
+	if err == nil {
+		return
+	}
+	a.logger.Error(err.Error(), slog.String("scope", scope), slog.Any("error", err))
+}
@@ -0,0 +1,76 @@
+//go:build go1.21
+
+package log
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stripTs - removes the timestamp so lines from different calls can be compared
+func stripTs(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	for i, line := range lines {
+		if j := strings.Index(line, `,"level"`); j > 0 {
+			lines[i] = line[j:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestSlogHandler(t *testing.T) {
+	l, _ := New("")
+	var buf bytes.Buffer
+	l.SetOutput(&buf, &buf)
+	l.SetStack(StackConfig{Disable: true})
+
+	l.OutFields("ORDERS", "created", String("id", "o1"), Int("qty", 2))
+	failed := errors.New("failed")
+	l.ErrorFields("ORDERS", failed, NamedErr("error", failed))
+	want := stripTs(buf.String())
+	buf.Reset()
+
+	logger := slog.New(NewSlogHandler(l, "DEFAULT"))
+	logger.Info("created", "scope", "ORDERS", "id", "o1", "qty", 2)
+	FromSlog(logger).Error("ORDERS", failed)
+	if got := stripTs(buf.String()); got != want {
+		t.Errorf("slog output differs\ngot  %s\nwant %s", got, want)
+	}
+	buf.Reset()
+
+	ctx := ContextWithRequestID(context.Background(), "r1")
+	logger.With("scope", "HTTP", "a", 1).WithGroup("req").With("path", "/").
+		InfoContext(ctx, "done", slog.Duration("took", time.Millisecond), slog.Group("user", "id", "u1"))
+	got := stripTs(buf.String())
+	if got != `,"level":"info","scope":"HTTP","msg":"done","request_id":"r1","a":1,"req":{"path":"/","took":1,"user":{"id":"u1"}}}` {
+		t.Errorf("unexpected grouped line %s", got)
+	}
+	buf.Reset()
+
+	l.SetLevel(LevelInfo)
+	logger.Debug("hidden")
+	l.SetLevel(LevelWarn)
+	logger.Info("hidden")
+	if buf.Len() != 0 {
+		t.Errorf("expected level of the logger to apply, got %s", buf.String())
+	}
+	FromSlog(logger).Out("SCOPE", "hidden")
+	FromSlog(logger).Error("SCOPE", nil)
+	if buf.Len() != 0 {
+		t.Errorf("expected nothing, got %s", buf.String())
+	}
+}
+
+func TestSlogAdapterError(t *testing.T) {
+	var buf bytes.Buffer
+	failed := errors.New("failed")
+	FromSlog(slog.New(slog.NewJSONHandler(&buf, nil))).Error("ORDERS", failed)
+	if !strings.Contains(buf.String(), `"msg":"failed","scope":"ORDERS","error":"failed"`) {
+		t.Errorf("expected the error attribute, got %s", buf.String())
+	}
+}
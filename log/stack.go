@@ -0,0 +1,128 @@
+package log
+
+import (
+	"errors"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// StackConfig - how error and fatal lines capture stacks, the zero value
+// attaches the full stack of the logging goroutine
+type StackConfig struct {
+	// Disable - no stack is attached
+	Disable bool
+	// Depth - keep only this many frames, starting at the caller of the
+	// logger, 0 keeps the full stack
+	Depth int
+	// FromError - when the error wraps a StackTracer, such as one created by
+	// WithStack at the failure site, its stack is used instead
+	FromError bool
+}
+
+// StackTracer - implemented by errors carrying the stack of where they were created
+type StackTracer interface {
+	StackTrace() []uintptr
+}
+
+// pkgDir - directory of this package, frames from its non test files belong
+// to the logger itself
+var pkgDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// maxDepth - frames captured by WithStack
+const maxDepth = 64
+
+// SetStack - changes how stacks are captured, the change is visible to every
+// copy of the logger
+func (l *Log) SetStack(cfg StackConfig) {
+	if l.core == nil {
+		l.core = newCore()
+	}
+	l.core.mu.Lock()
+	l.core.stack = cfg
+	l.core.mu.Unlock()
+}
+
+// WithStack - wraps err with the stack of the caller, nil stays nil
+func WithStack(err error) error {
+	if err == nil {
+		return nil
+	}
+	pcs := make([]uintptr, maxDepth)
+	n := runtime.Callers(2, pcs)
+	return &stackError{err: err, pcs: pcs[:n]}
+}
+
+type stackError struct {
+	err error
+	pcs []uintptr
+}
+
+func (e *stackError) Error() string {
+	return e.err.Error()
+}
+
+func (e *stackError) Unwrap() error {
+	return e.err
+}
+
+// StackTrace - program counters of where the error was wrapped
+func (e *stackError) StackTrace() []uintptr {
+	return e.pcs
+}
+
+// captureStack - returns the stack to attach to a line about err
+func (c *core) captureStack(err error) string {
+	var cfg StackConfig
+	if c != nil {
+		c.mu.RLock()
+		cfg = c.stack
+		c.mu.RUnlock()
+	}
+	if cfg.Disable {
+		return ""
+	}
+	if cfg.FromError && err != nil {
+		var st StackTracer
+		if errors.As(err, &st) {
+			return formatFrames(st.StackTrace(), cfg.Depth, false)
+		}
+	}
+	if cfg.Depth <= 0 {
+		return string(debug.Stack())
+	}
+	pcs := make([]uintptr, cfg.Depth+maxDepth)
+	n := runtime.Callers(2, pcs)
+	return formatFrames(pcs[:n], cfg.Depth, true)
+}
+
+// formatFrames - renders frames like debug.Stack, optionally skipping the
+// leading frames of this package
+func formatFrames(pcs []uintptr, depth int, skipOwn bool) string {
+	var b strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	n := 0
+	for {
+		f, more := frames.Next()
+		if skipOwn && filepath.Dir(f.File) == pkgDir && !strings.HasSuffix(f.File, "_test.go") {
+			if !more {
+				break
+			}
+			continue
+		}
+		skipOwn = false
+		if f.Function != "" {
+			b.WriteString(f.Function + "()\n\t" + f.File + ":" + strconv.Itoa(f.Line) + "\n")
+			n++
+		}
+		if !more || (depth > 0 && n >= depth) {
+			break
+		}
+	}
+	return b.String()
+}
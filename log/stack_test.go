@@ -0,0 +1,62 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func stackOf(t *testing.T, buf *bytes.Buffer) string {
+	t.Helper()
+	var line map[string]interface{}
+	if e := json.Unmarshal(buf.Bytes(), &line); e != nil {
+		t.Fatalf("invalid line %q: %v", buf.String(), e)
+	}
+	buf.Reset()
+	s, _ := line["stack"].(string)
+	return s
+}
+
+func failingCall() error {
+	return WithStack(errors.New("failed"))
+}
+
+func TestStack(t *testing.T) {
+	l, _ := New("")
+	var buf bytes.Buffer
+	l.SetOutput(&buf, &buf)
+
+	l.Error("STACK", errors.New("full"))
+	if s := stackOf(t, &buf); !strings.Contains(s, "goroutine") || !strings.Contains(s, "log.Log.Error") {
+		t.Errorf("default should be the full stack, got %q", s)
+	}
+
+	l.SetStack(StackConfig{Disable: true})
+	l.Error("STACK", errors.New("none"))
+	if s := stackOf(t, &buf); s != "" {
+		t.Errorf("expected no stack, got %q", s)
+	}
+
+	l.SetStack(StackConfig{Depth: 2})
+	l.Error("STACK", errors.New("short"))
+	s := stackOf(t, &buf)
+	if strings.Count(s, "\n\t") != 2 || !strings.HasPrefix(s, "github.com/kelchy/go-lib/log.TestStack") {
+		t.Errorf("expected 2 frames starting at the caller, got %q", s)
+	}
+
+	l.SetStack(StackConfig{Depth: 1, FromError: true})
+	err := fmt.Errorf("wrapped: %w", failingCall())
+	l.Error("STACK", err)
+	if s := stackOf(t, &buf); !strings.HasPrefix(s, "github.com/kelchy/go-lib/log.failingCall") {
+		t.Errorf("expected stack of the error, got %q", s)
+	}
+	if WithStack(nil) != nil {
+		t.Error("WithStack(nil) should be nil")
+	}
+	if !errors.Is(err, errors.Unwrap(errors.Unwrap(err))) {
+		t.Error("WithStack should unwrap to the original error")
+	}
+}